@@ -5,8 +5,12 @@ import (
 	"errors"
 	"felipewom/go-env-prepare/cmd/install"
 	"felipewom/go-env-prepare/internal/dynamic"
+	"felipewom/go-env-prepare/internal/progress"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
@@ -24,6 +28,22 @@ type dynamicFlags struct {
 	Resume       bool
 	StatePath    string
 	LockfilePath string
+	CatalogPaths []string
+	OutputSARIF  bool
+	Strict       bool
+	Frozen       bool
+	Jobs         int
+	KeepGoing    bool
+	Retries      int
+	RetryDelay   time.Duration
+	RetryBackoff string
+	Timeout      time.Duration
+	Force        bool
+	Rollback     bool
+	LogDir       string
+	Tee          bool
+	Events       string
+	Plain        bool
 }
 
 func NewCommands() *Commands {
@@ -33,6 +53,10 @@ func NewCommands() *Commands {
 	rootCmd.RootCmd.AddCommand(newRunCmd())
 	rootCmd.RootCmd.AddCommand(newLintCmd())
 	rootCmd.RootCmd.AddCommand(newLockCmd())
+	rootCmd.RootCmd.AddCommand(newVerifyCmd())
+	rootCmd.RootCmd.AddCommand(newUninstallCmd())
+	rootCmd.RootCmd.AddCommand(newUpdateCmd())
+	rootCmd.RootCmd.AddCommand(newListCmd())
 	return rootCmd
 }
 
@@ -74,28 +98,91 @@ func newRunCmd() *cobra.Command {
 		Use:   "run",
 		Short: "Execute profile plan with idempotency and optional dry-run",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			plan, _, err := buildPlan(flags)
+			var plan dynamic.Plan
+			var manifest dynamic.Manifest
+			var err error
+			if flags.Frozen {
+				plan, err = buildFrozenPlan(flags)
+			} else {
+				plan, manifest, err = buildPlan(flags)
+			}
 			if err != nil {
 				return err
 			}
-			executor := dynamic.NewExecutor()
-			result, runErr := executor.Run(plan, dynamic.ExecOptions{
+			opts := dynamic.ExecOptions{
 				DryRun:    flags.DryRun,
 				Resume:    flags.Resume,
 				StatePath: flags.StatePath,
-			})
-			if flags.OutputJSON {
+				Jobs:      flags.Jobs,
+				KeepGoing: flags.KeepGoing,
+				Timeout:   flags.Timeout,
+
+				RollbackOnFailure: flags.Rollback,
+				LogDir:            flags.LogDir,
+				Force:             flags.Force,
+			}
+			if flags.Tee {
+				opts.LogOutput = os.Stderr
+			}
+			if cmd.Flags().Changed("retries") || cmd.Flags().Changed("retry-delay") || cmd.Flags().Changed("retry-backoff") {
+				opts.Retry = &dynamic.RetryPolicy{Retries: flags.Retries, Delay: flags.RetryDelay.String(), Backoff: flags.RetryBackoff}
+			}
+			executor := dynamic.NewExecutor()
+			if flags.Frozen {
+				if err := executor.CheckPinnable(plan); err != nil {
+					return err
+				}
+			}
+			switch flags.Events {
+			case "":
+			case "ndjson":
+				executor.Observe(dynamic.NewNDJSONObserver(os.Stdout))
+			default:
+				return fmt.Errorf("unsupported --events format %q (want ndjson)", flags.Events)
+			}
+			var renderer *progress.Renderer
+			if flags.Events == "" && !flags.OutputJSON {
+				// Teed output goes to the same terminal, so it cannot share it with a live display.
+				renderer = progress.New(os.Stdout, progress.DetectOptions(os.Stdout, flags.Plain || flags.Tee))
+				executor.Observe(dynamic.NewProgressObserver(renderer))
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go func() {
+				// After the first signal, a second one terminates immediately.
+				<-ctx.Done()
+				stop()
+			}()
+			result, runErr := executor.RunContext(ctx, plan, opts)
+			if renderer != nil {
+				renderer.Close()
+			}
+			switch {
+			case flags.Events != "":
+				// The run_finished event already carries the result.
+			case flags.OutputJSON:
 				if err := dynamic.PrintJSON(result); err != nil {
 					return err
 				}
-			} else {
+			default:
 				dynamic.PrintExecutionHuman(result)
 			}
 			if runErr != nil {
 				return runErr
 			}
-			if flags.LockfilePath != "" {
+			if flags.LockfilePath != "" && !flags.Frozen && !flags.DryRun {
 				lock := dynamic.BuildLockfile(plan)
+				lock.ManifestHash = manifest.Digest()
+				versions := dynamic.InstalledVersions(result)
+				// Tools skipped on resume were not probed during this run.
+				unprobed := dynamic.Plan{}
+				for _, step := range plan.Steps {
+					if versions[step.Tool.ID] == "" {
+						unprobed.Steps = append(unprobed.Steps, step)
+					}
+				}
+				lock.ResolveVersions(executor.ProbeVersions(unprobed))
+				lock.ResolveVersions(versions)
 				if err := writeJSONFile(flags.LockfilePath, lock); err != nil {
 					return fmt.Errorf("write lockfile: %w", err)
 				}
@@ -106,37 +193,61 @@ func newRunCmd() *cobra.Command {
 	bindDynamicFlags(cmd, flags)
 	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Show execution result without mutating machine")
 	cmd.Flags().BoolVar(&flags.Resume, "resume", false, "Resume from previous checkpoint state")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Resume even if the state file was written for a different plan")
 	cmd.Flags().StringVar(&flags.StatePath, "state", ".prepare.state.json", "Checkpoint state file path")
 	cmd.Flags().StringVar(&flags.LockfilePath, "lockfile", "prepare.lock.json", "Write lockfile after successful run")
+	cmd.Flags().IntVarP(&flags.Jobs, "jobs", "j", 1, "Number of independent steps to run concurrently")
+	cmd.Flags().BoolVar(&flags.KeepGoing, "keep-going", false, "Continue after a failed step, skipping only the tools that depend on it")
+	cmd.Flags().IntVar(&flags.Retries, "retries", 0, "Retry failed installs this many times, overriding each tool's retry policy")
+	cmd.Flags().DurationVar(&flags.RetryDelay, "retry-delay", time.Second, "Delay before the first retry")
+	cmd.Flags().StringVar(&flags.RetryBackoff, "retry-backoff", dynamic.BackoffExponential, "Retry backoff: fixed or exponential")
+	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 0, "Kill an install attempt after this long, overriding each tool's timeout (0 keeps the tool's own)")
+	cmd.Flags().BoolVar(&flags.Rollback, "rollback-on-failure", false, "Uninstall the tools installed by this run, newest first, if the run fails")
+	cmd.Flags().BoolVar(&flags.Frozen, "frozen", false, "Install the tools and versions pinned in the lockfile instead of resolving profiles")
+	cmd.Flags().StringVar(&flags.LogDir, "log-dir", dynamic.DefaultLogDir(), "Directory for per-run step logs (empty disables them)")
+	cmd.Flags().BoolVar(&flags.Tee, "tee", false, "Also stream step output to stderr, prefixed with the tool id")
+	cmd.Flags().StringVar(&flags.Events, "events", "", "Stream run events to stdout as they happen (ndjson)")
+	cmd.Flags().BoolVar(&flags.Plain, "plain", false, "Print one line per step status change instead of a live progress display")
 	return cmd
 }
 
 func newLintCmd() *cobra.Command {
 	flags := &dynamicFlags{}
 	cmd := &cobra.Command{
-		Use:   "lint",
-		Short: "Validate manifest syntax and semantic constraints",
+		Use:          "lint",
+		Short:        "Validate manifest syntax and semantic constraints",
+		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			manifest, err := loadManifestForFlags(flags)
+			catalog, err := loadCatalogForFlags(flags)
 			if err != nil {
 				return err
 			}
-			catalog := dynamic.BuiltinCatalog()
-			profiles := dynamic.BuiltinProfiles()
-			if err := dynamic.ValidateManifest(manifest, catalog, profiles); err != nil {
-				if flags.OutputJSON {
-					_ = dynamic.PrintJSON(map[string]any{"valid": false, "error": err.Error()})
-				}
+			diagnostics, err := lintManifestForFlags(flags, catalog)
+			if err != nil {
 				return err
 			}
-			if flags.OutputJSON {
-				return dynamic.PrintJSON(map[string]any{"valid": true})
+			failed := dynamic.HasErrors(diagnostics) || (flags.Strict && len(diagnostics) > 0)
+			switch {
+			case flags.OutputSARIF:
+				if err := dynamic.PrintJSON(dynamic.BuildSARIF(diagnostics)); err != nil {
+					return err
+				}
+			case flags.OutputJSON:
+				if err := dynamic.PrintJSON(map[string]any{"valid": !failed, "diagnostics": diagnostics}); err != nil {
+					return err
+				}
+			default:
+				dynamic.PrintDiagnosticsHuman(diagnostics)
+			}
+			if failed {
+				return errors.New("manifest is invalid")
 			}
-			fmt.Println("manifest is valid")
 			return nil
 		},
 	}
 	bindDynamicFlags(cmd, flags)
+	cmd.Flags().BoolVar(&flags.OutputSARIF, "sarif", false, "Emit diagnostics as SARIF 2.1.0")
+	cmd.Flags().BoolVar(&flags.Strict, "strict", false, "Fail on warnings as well as errors")
 	return cmd
 }
 
@@ -146,14 +257,17 @@ func newLockCmd() *cobra.Command {
 		Use:   "lock",
 		Short: "Generate a lockfile from the resolved execution plan",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			plan, _, err := buildPlan(flags)
+			plan, manifest, err := buildPlan(flags)
 			if err != nil {
 				return err
 			}
 			if flags.LockfilePath == "" {
 				flags.LockfilePath = "prepare.lock.json"
 			}
-			if err := writeJSONFile(flags.LockfilePath, dynamic.BuildLockfile(plan)); err != nil {
+			lock := dynamic.BuildLockfile(plan)
+			lock.ManifestHash = manifest.Digest()
+			lock.ResolveVersions(dynamic.NewExecutor().ProbeVersions(plan))
+			if err := writeJSONFile(flags.LockfilePath, lock); err != nil {
 				return err
 			}
 			if flags.OutputJSON {
@@ -168,10 +282,245 @@ func newLockCmd() *cobra.Command {
 	return cmd
 }
 
+func newVerifyCmd() *cobra.Command {
+	flags := &dynamicFlags{}
+	cmd := &cobra.Command{
+		Use:          "verify",
+		Short:        "Check the machine against the lockfile and report drift",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := verifyLockfile(flags)
+			if err != nil {
+				return err
+			}
+			if flags.OutputJSON {
+				if err := dynamic.PrintJSON(report); err != nil {
+					return err
+				}
+			} else {
+				dynamic.PrintVerifyHuman(report)
+			}
+			if report.HasDrift() {
+				return errors.New("machine does not match the lockfile")
+			}
+			return nil
+		},
+	}
+	bindDynamicFlags(cmd, flags)
+	cmd.Flags().StringVar(&flags.LockfilePath, "lockfile", "prepare.lock.json", "Lockfile path")
+	return cmd
+}
+
+func verifyLockfile(flags *dynamicFlags) (dynamic.VerifyReport, error) {
+	lock, err := dynamic.LoadLockfile(flags.LockfilePath)
+	if err != nil {
+		return dynamic.VerifyReport{}, fmt.Errorf("load lockfile: %w", err)
+	}
+	manifest, err := loadManifestForFlags(flags)
+	if err != nil {
+		return dynamic.VerifyReport{}, err
+	}
+	catalog, err := loadCatalogForFlags(flags)
+	if err != nil {
+		return dynamic.VerifyReport{}, err
+	}
+	report := dynamic.NewExecutor().Verify(lock, dynamic.MergeCatalog(catalog, manifest.ToolSpecs))
+	report.Lockfile = flags.LockfilePath
+	return report, nil
+}
+
+func newUpdateCmd() *cobra.Command {
+	flags := &dynamicFlags{}
+	cmd := &cobra.Command{
+		Use:   "update [tool...]",
+		Short: "Upgrade installed tools in dependency order and refresh the lockfile",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executor := dynamic.NewExecutor()
+			plan, err := buildUpdatePlan(flags, args, executor)
+			if err != nil {
+				return err
+			}
+			result, runErr := executor.Update(cmd.Context(), plan, dynamic.UpdateOptions{
+				DryRun: flags.DryRun,
+				Output: os.Stderr,
+			})
+			if flags.OutputJSON {
+				if err := dynamic.PrintJSON(result); err != nil {
+					return err
+				}
+			} else {
+				dynamic.PrintExecutionHuman(result)
+			}
+			if runErr != nil || flags.DryRun {
+				return runErr
+			}
+			return refreshLockfile(flags.LockfilePath, dynamic.InstalledVersions(result))
+		},
+	}
+	bindDynamicFlags(cmd, flags)
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Show what would be upgraded without mutating machine")
+	cmd.Flags().StringVar(&flags.LockfilePath, "lockfile", "prepare.lock.json", "Lockfile to refresh with the upgraded versions, if it exists")
+	return cmd
+}
+
+func newListCmd() *cobra.Command {
+	flags := &dynamicFlags{}
+	var installedOnly, missingOnly bool
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List catalog tools with their install status, version and profiles",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if installedOnly && missingOnly {
+				return errors.New("--installed and --missing cannot be used together")
+			}
+			manifest, err := loadManifestForFlags(flags)
+			if err != nil {
+				return err
+			}
+			catalog, err := loadCatalogForFlags(flags)
+			if err != nil {
+				return err
+			}
+			catalog = dynamic.MergeCatalog(catalog, manifest.ToolSpecs)
+			membership := dynamic.ToolProfiles(manifest, dynamic.BuiltinProfiles())
+			listings := []dynamic.ToolListing{}
+			for _, l := range dynamic.NewExecutor().List(catalog, membership) {
+				if (installedOnly && !l.Installed) || (missingOnly && l.Installed) {
+					continue
+				}
+				listings = append(listings, l)
+			}
+			if flags.OutputJSON {
+				return dynamic.PrintJSON(listings)
+			}
+			dynamic.PrintToolListHuman(listings)
+			return nil
+		},
+	}
+	bindDynamicFlags(cmd, flags)
+	cmd.Flags().BoolVar(&installedOnly, "installed", false, "Only list installed tools")
+	cmd.Flags().BoolVar(&missingOnly, "missing", false, "Only list tools that are not installed")
+	return cmd
+}
+
+func newUninstallCmd() *cobra.Command {
+	flags := &dynamicFlags{}
+	cmd := &cobra.Command{
+		Use:   "uninstall [tool...]",
+		Short: "Remove tools in reverse dependency order",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, catalog, err := buildUninstallPlan(flags, args)
+			if err != nil {
+				return err
+			}
+			executor := dynamic.NewExecutor()
+			result, runErr := executor.Uninstall(cmd.Context(), plan, catalog, dynamic.UninstallOptions{
+				DryRun:    flags.DryRun,
+				Force:     flags.Force,
+				StatePath: flags.StatePath,
+				Output:    os.Stderr,
+			})
+			if flags.OutputJSON {
+				if err := dynamic.PrintJSON(result); err != nil {
+					return err
+				}
+			} else {
+				dynamic.PrintExecutionHuman(result)
+			}
+			return runErr
+		},
+	}
+	bindDynamicFlags(cmd, flags)
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Show what would be removed without mutating machine")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Remove tools even if installed tools still depend on them")
+	cmd.Flags().StringVar(&flags.StatePath, "state", ".prepare.state.json", "Checkpoint state file to drop removed tools from")
+	return cmd
+}
+
+// buildUninstallPlan plans the tools named in args, or the tools of
+// --profile when no tool is named.
+func buildUninstallPlan(flags *dynamicFlags, args []string) (dynamic.Plan, map[string]dynamic.ToolSpec, error) {
+	if len(args) == 0 && flags.Profile == "" {
+		return dynamic.Plan{}, nil, errors.New("name the tools to uninstall or select a --profile")
+	}
+	manifest, err := loadManifestForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, nil, err
+	}
+	catalog, err := loadCatalogForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, nil, err
+	}
+	catalog = dynamic.MergeCatalog(catalog, manifest.ToolSpecs)
+	tools := args
+	if len(tools) == 0 {
+		profileOnly := manifest
+		profileOnly.Tools = nil
+		tools, err = dynamic.ResolveTools(profileOnly, flags.Profile, catalog, dynamic.BuiltinProfiles())
+		if err != nil {
+			return dynamic.Plan{}, nil, err
+		}
+	}
+	plan, err := dynamic.BuildUninstallPlan(tools, catalog)
+	if err != nil {
+		return dynamic.Plan{}, nil, err
+	}
+	return plan, catalog, nil
+}
+
+// buildUpdatePlan plans the installed tools among args, or among the tools
+// --profile resolves to when no tools are named. Without --profile the
+// manifest's own tools and profile are used.
+func buildUpdatePlan(flags *dynamicFlags, args []string, executor *dynamic.Executor) (dynamic.Plan, error) {
+	manifest, err := loadManifestForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, err
+	}
+	catalog, err := loadCatalogForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, err
+	}
+	catalog = dynamic.MergeCatalog(catalog, manifest.ToolSpecs)
+	tools := args
+	if len(tools) == 0 {
+		selected := manifest
+		if flags.Profile != "" {
+			selected.Tools = nil
+		}
+		tools, err = dynamic.ResolveTools(selected, flags.Profile, catalog, dynamic.BuiltinProfiles())
+		if err != nil {
+			return dynamic.Plan{}, err
+		}
+	}
+	return executor.BuildUpdatePlan(tools, catalog)
+}
+
+// refreshLockfile records versions in the lockfile at path. A missing
+// lockfile is left missing.
+func refreshLockfile(path string, versions map[string]string) error {
+	if path == "" {
+		return nil
+	}
+	lock, err := dynamic.LoadLockfile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lock.ResolveVersions(versions)
+	lock.GeneratedAt = time.Now()
+	if err := writeJSONFile(path, lock); err != nil {
+		return fmt.Errorf("write lockfile: %w", err)
+	}
+	return nil
+}
+
 func bindDynamicFlags(cmd *cobra.Command, flags *dynamicFlags) {
 	cmd.Flags().StringVarP(&flags.ManifestPath, "file", "f", "", "Manifest file path (prepare.yaml|prepare.json)")
 	cmd.Flags().StringVarP(&flags.Profile, "profile", "p", "", "Profile name to execute")
 	cmd.Flags().BoolVar(&flags.OutputJSON, "json", false, "Emit JSON output")
+	cmd.Flags().StringArrayVar(&flags.CatalogPaths, "catalog", nil, "Tool definition file or directory layered over the builtin catalog (repeatable)")
 }
 
 func buildPlan(flags *dynamicFlags) (dynamic.Plan, dynamic.Manifest, error) {
@@ -180,7 +529,11 @@ func buildPlan(flags *dynamicFlags) (dynamic.Plan, dynamic.Manifest, error) {
 		return dynamic.Plan{}, dynamic.Manifest{}, err
 	}
 
-	catalog := dynamic.BuiltinCatalog()
+	catalog, err := loadCatalogForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, dynamic.Manifest{}, err
+	}
+	catalog = dynamic.MergeCatalog(catalog, manifest.ToolSpecs)
 	builtinProfiles := dynamic.BuiltinProfiles()
 	if err := dynamic.ValidateManifest(manifest, catalog, builtinProfiles); err != nil {
 		return dynamic.Plan{}, dynamic.Manifest{}, err
@@ -196,6 +549,52 @@ func buildPlan(flags *dynamicFlags) (dynamic.Plan, dynamic.Manifest, error) {
 	return plan, manifest, nil
 }
 
+// buildFrozenPlan plans the tools pinned in the lockfile. It refuses to run
+// when the manifest changed since the lockfile was written.
+func buildFrozenPlan(flags *dynamicFlags) (dynamic.Plan, error) {
+	lock, err := dynamic.LoadLockfile(flags.LockfilePath)
+	if err != nil {
+		return dynamic.Plan{}, fmt.Errorf("load lockfile: %w", err)
+	}
+	manifest, err := loadManifestForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, err
+	}
+	if lock.ManifestHash != manifest.Digest() {
+		return dynamic.Plan{}, fmt.Errorf("manifest does not match %s; run prepare lock to update it", flags.LockfilePath)
+	}
+	catalog, err := loadCatalogForFlags(flags)
+	if err != nil {
+		return dynamic.Plan{}, err
+	}
+	return dynamic.FrozenPlan(lock, dynamic.MergeCatalog(catalog, manifest.ToolSpecs))
+}
+
+func loadCatalogForFlags(flags *dynamicFlags) (map[string]dynamic.ToolSpec, error) {
+	if len(flags.CatalogPaths) == 0 {
+		return dynamic.BuiltinCatalog(), nil
+	}
+	return dynamic.LoadCatalog(dynamic.BuiltinCatalog(), flags.CatalogPaths)
+}
+
+func lintManifestForFlags(flags *dynamicFlags, catalog map[string]dynamic.ToolSpec) ([]dynamic.Diagnostic, error) {
+	manifest := dynamic.Manifest{APIVersion: "v1", Profiles: map[string]dynamic.Profile{}}
+	path, err := dynamic.DiscoverManifestPath(flags.ManifestPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		manifest, err = dynamic.LoadManifest(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err != nil {
+			return []dynamic.Diagnostic{dynamic.DiagnosticFromError(path, err)}, nil
+		}
+	}
+	return dynamic.LintManifest(manifest, path, catalog, dynamic.BuiltinProfiles()), nil
+}
+
 func loadManifestForFlags(flags *dynamicFlags) (dynamic.Manifest, error) {
 	manifest := dynamic.Manifest{APIVersion: "v1", Profiles: map[string]dynamic.Profile{}}
 	path, err := dynamic.DiscoverManifestPath(flags.ManifestPath)
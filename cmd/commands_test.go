@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"felipewom/go-env-prepare/internal/dynamic"
 	"os"
 	"path/filepath"
 	"strings"
@@ -78,7 +79,125 @@ func TestBuildPlanInvalidDiscoveredManifestReturnsError(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for invalid discovered manifest")
 	}
-	if !strings.Contains(err.Error(), "unsupported manifest syntax") {
+	if !strings.Contains(err.Error(), "invalid YAML manifest: line 3") {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestBuildPlanWithCatalogFlag(t *testing.T) {
+	tmp := t.TempDir()
+	definition := "title: Acme CLI\ndependencies: [go]\ninstall:\n  name: go\n  args: [install, example.com/acme@latest]\ncheck:\n  binary: acme\n"
+	if err := os.WriteFile(filepath.Join(tmp, "acme.yaml"), []byte(definition), 0o644); err != nil {
+		t.Fatalf("write definition: %v", err)
+	}
+	manifest := "apiVersion: v1\nprofile: base\ntools: [acme]\n"
+	if err := os.WriteFile(filepath.Join(tmp, "prepare.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	plan, _, err := buildPlan(&dynamicFlags{
+		ManifestPath: filepath.Join(tmp, "prepare.yaml"),
+		CatalogPaths: []string{filepath.Join(tmp, "acme.yaml")},
+	})
+	if err != nil {
+		t.Fatalf("buildPlan error: %v", err)
+	}
+	order := map[string]int{}
+	for _, step := range plan.Steps {
+		order[step.Tool.ID] = step.Order
+	}
+	if order["acme"] == 0 || order["acme"] <= order["go"] {
+		t.Fatalf("expected acme planned after go, got %#v", order)
+	}
+}
+
+func TestBuildFrozenPlanRejectsChangedManifest(t *testing.T) {
+	tmp := t.TempDir()
+	manifestPath := filepath.Join(tmp, "prepare.yaml")
+	lockPath := filepath.Join(tmp, "prepare.lock.json")
+	if err := os.WriteFile(manifestPath, []byte("apiVersion: v1\nprofile: base\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	flags := &dynamicFlags{ManifestPath: manifestPath, LockfilePath: lockPath}
+	plan, manifest, err := buildPlan(flags)
+	if err != nil {
+		t.Fatalf("buildPlan error: %v", err)
+	}
+	lock := dynamic.BuildLockfile(plan)
+	lock.ManifestHash = manifest.Digest()
+	if err := writeJSONFile(lockPath, lock); err != nil {
+		t.Fatalf("write lockfile: %v", err)
+	}
+
+	frozen, err := buildFrozenPlan(flags)
+	if err != nil {
+		t.Fatalf("buildFrozenPlan error: %v", err)
+	}
+	if len(frozen.Steps) != len(plan.Steps) {
+		t.Fatalf("expected frozen plan to match locked plan, got %d steps", len(frozen.Steps))
+	}
+
+	if err := os.WriteFile(manifestPath, []byte("apiVersion: v1\nprofile: backend\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	if _, err := buildFrozenPlan(flags); err == nil || !strings.Contains(err.Error(), "manifest does not match") {
+		t.Fatalf("expected manifest mismatch error, got %v", err)
+	}
+}
+
+func TestBuildUpdatePlanWithProfileIgnoresManifestTools(t *testing.T) {
+	tmp := t.TempDir()
+	var catalogPaths []string
+	for _, id := range []string{"alpha", "beta"} {
+		definition := "title: " + id + "\ninstall:\n  name: true\nupgrade:\n  name: true\ncheck:\n  command:\n    name: true\n"
+		path := filepath.Join(tmp, id+".yaml")
+		if err := os.WriteFile(path, []byte(definition), 0o644); err != nil {
+			t.Fatalf("write definition: %v", err)
+		}
+		catalogPaths = append(catalogPaths, path)
+	}
+	manifest := "apiVersion: v1\ntools: [alpha]\nprofiles:\n  only-beta:\n    tools: [beta]\n"
+	if err := os.WriteFile(filepath.Join(tmp, "prepare.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	plan, err := buildUpdatePlan(&dynamicFlags{
+		ManifestPath: filepath.Join(tmp, "prepare.yaml"),
+		CatalogPaths: catalogPaths,
+		Profile:      "only-beta",
+	}, nil, dynamic.NewExecutor())
+	if err != nil {
+		t.Fatalf("buildUpdatePlan error: %v", err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Tool.ID != "beta" {
+		t.Fatalf("expected only beta planned, got %#v", plan.Steps)
+	}
+}
+
+func TestRefreshLockfileRecordsUpgradedVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prepare.lock.json")
+	if err := refreshLockfile(path, map[string]string{"git": "2.45.1"}); err != nil {
+		t.Fatalf("refreshLockfile without a lockfile: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no lockfile to be created, got %v", err)
+	}
+
+	lock := dynamic.Lockfile{Version: dynamic.LockfileVersion, Tools: []dynamic.LockedTool{
+		{ID: "git", Version: "2.44.0", Requested: "latest"},
+		{ID: "go", Version: "1.22.1", Requested: "1.22.1"},
+	}}
+	if err := writeJSONFile(path, lock); err != nil {
+		t.Fatalf("write lockfile: %v", err)
+	}
+	if err := refreshLockfile(path, map[string]string{"git": "2.45.1"}); err != nil {
+		t.Fatalf("refreshLockfile error: %v", err)
+	}
+	refreshed, err := dynamic.LoadLockfile(path)
+	if err != nil {
+		t.Fatalf("LoadLockfile error: %v", err)
+	}
+	if refreshed.Tools[0].Version != "2.45.1" || refreshed.Tools[1].Version != "1.22.1" {
+		t.Fatalf("unexpected refreshed tools: %#v", refreshed.Tools)
+	}
+}
@@ -1,8 +1,26 @@
 package install
 
-import "time"
+import (
+	"felipewom/go-env-prepare/internal/progress"
+	"os"
+	"os/exec"
+)
 
-func loadingAnimation() string {
-	animations := []string{"⌛", "⌛⌛", "⌛⌛⌛", "⌛⌛⌛⌛"}
-	return animations[time.Now().Second()%4]
+// runWithProgress runs cmd as a single progress step, showing the tail of
+// its output while it runs instead of streaming all of it.
+func runWithProgress(title string, cmd *exec.Cmd) error {
+	r := progress.New(os.Stdout, progress.DetectOptions(os.Stdout, false))
+	defer r.Close()
+	out := r.Writer(title)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	r.Start(title)
+	err := cmd.Run()
+	out.Close()
+	if err != nil {
+		r.Finish(title, progress.StatusFailed, err.Error())
+		return err
+	}
+	r.Finish(title, progress.StatusDone, "")
+	return nil
 }
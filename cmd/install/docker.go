@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"time"
 )
 
 type DockerInstaller struct{}
@@ -18,36 +17,15 @@ func (d *DockerInstaller) Install() {
 
 	fmt.Println("Docker is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Docker %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install Docker Desktop using Homebrew cask
 	cmd := exec.Command("brew", "install", "--cask", "docker")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing Docker", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Docker: %v\n", err)
+		fmt.Printf("❌ Error installing Docker: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Docker installed successfully.\n")
+	fmt.Printf("✅ Docker installed successfully.\n")
 
 	// Perform post-installation guidance
 	d.configureDocker()
@@ -2,9 +2,7 @@ package install
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
-	"time"
 )
 
 type DotnetInstaller struct{}
@@ -18,36 +16,15 @@ func (d *DotnetInstaller) Install() {
 
 	fmt.Println(".NET SDK is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing .NET SDK %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install .NET SDK using Homebrew
 	cmd := exec.Command("brew", "install", "dotnet-sdk")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing .NET SDK", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing .NET SDK: %v\n", err)
+		fmt.Printf("❌ Error installing .NET SDK: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ .NET SDK installed successfully.\n")
+	fmt.Printf("✅ .NET SDK installed successfully.\n")
 }
 
 func (d *DotnetInstaller) Title() string {
@@ -2,9 +2,7 @@ package install
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
-	"time"
 )
 
 type GitInstaller struct{}
@@ -17,36 +15,15 @@ func (g *GitInstaller) Install() {
 	}
 	fmt.Println("Git is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Git %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install Git using Homebrew
 	cmd := exec.Command("brew", "install", "git")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing Git", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Git: %v\n", err)
+		fmt.Printf("❌ Error installing Git: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Git installed successfully.\n")
+	fmt.Printf("✅ Git installed successfully.\n")
 }
 
 func (g *GitInstaller) Title() string {
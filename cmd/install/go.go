@@ -2,9 +2,7 @@ package install
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
-	"time"
 )
 
 type GoInstaller struct{}
@@ -18,36 +16,15 @@ func (g *GoInstaller) Install() {
 
 	fmt.Println("Go is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Go %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install Go using Homebrew
 	cmd := exec.Command("brew", "install", "go")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing Go", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Go: %v\n", err)
+		fmt.Printf("❌ Error installing Go: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Go installed successfully.\n")
+	fmt.Printf("✅ Go installed successfully.\n")
 }
 
 func (g *GoInstaller) Title() string {
@@ -6,7 +6,6 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
-	"time"
 )
 
 type HomebrewInstaller struct{}
@@ -20,20 +19,6 @@ func (h *HomebrewInstaller) Install() {
 
 	fmt.Println("Homebrew is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Homebrew %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	var cmd *exec.Cmd
 
 	// Determine the architecture for Homebrew installation
@@ -47,19 +32,13 @@ func (h *HomebrewInstaller) Install() {
 	cmd = exec.Command("/bin/bash", "-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)")
 	cmd.Env = append(os.Environ(), "HOMEBREW_ARCH="+arch)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
+	err := runWithProgress("Installing Homebrew", cmd)
 	if err != nil {
-		close(done) // Stop the loading animation
-		fmt.Println("\rError installing Homebrew:", err)
+		fmt.Println("Error installing Homebrew:", err)
 		return
 	}
 
-	// Stop the loading animation
-	close(done)
-	fmt.Printf("\r✅ Homebrew installed successfully.\n")
+	fmt.Printf("✅ Homebrew installed successfully.\n")
 }
 
 func (h *HomebrewInstaller) Title() string {
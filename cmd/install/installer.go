@@ -90,7 +90,7 @@ func RunInstaller(i Installer) {
 			return
 		}
 		if err := lifecycleInstaller.Apply(); err != nil {
-			fmt.Printf("\r❌ Error installing %s: %v\n", i.Title(), err)
+			fmt.Printf("❌ Error installing %s: %v\n", i.Title(), err)
 		}
 		return
 	}
@@ -98,24 +98,24 @@ func RunInstaller(i Installer) {
 }
 
 func FinishAllInstallations() {
-	fmt.Printf("\r✅ Installation steps finished.\n")
+	fmt.Printf("✅ Installation steps finished.\n")
 
 	// Reload the shell configuration
 	err := reloadShellConfiguration()
 	if err != nil {
-		fmt.Printf("\r⚠️ Install completed, but shell configuration was not reloaded automatically: %v\n", err)
-		fmt.Printf("\r⚠️ Please run `source ~/.zshrc` (or restart your shell) to load changes.\n")
+		fmt.Printf("⚠️ Install completed, but shell configuration was not reloaded automatically: %v\n", err)
+		fmt.Printf("⚠️ Please run `source ~/.zshrc` (or restart your shell) to load changes.\n")
 		return
 	}
 
-	fmt.Printf("\r✅ Shell configuration reloaded. Some tools may still require a new terminal session.\n")
+	fmt.Printf("✅ Shell configuration reloaded. Some tools may still require a new terminal session.\n")
 }
 
 func reloadShellConfiguration() error {
 	// Get the user's home directory
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return err
 	}
 
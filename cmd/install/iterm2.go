@@ -7,7 +7,6 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 type Iterm2Installer struct{}
@@ -22,36 +21,15 @@ func (i *Iterm2Installer) Install() {
 
 	fmt.Println("iTerm2 is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing iTerm2 %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install iTerm2 using Homebrew
 	cmd := exec.Command("brew", "install", "--cask", "iterm2")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing iTerm2", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing iTerm2: %v\n", err)
+		fmt.Printf("❌ Error installing iTerm2: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ iTerm2 installed successfully.\n")
+	fmt.Printf("✅ iTerm2 installed successfully.\n")
 
 	// Set iTerm2 as the default terminal
 	i.setDefaultTerminal()
@@ -81,7 +59,7 @@ func (i *Iterm2Installer) setDefaultTerminal() {
 	// Get the user's home directory
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return
 	}
 
@@ -107,9 +85,9 @@ func (i *Iterm2Installer) setDefaultTerminal() {
 	err = cmd.Run()
 
 	if err != nil {
-		fmt.Printf("\r❌ Error setting iTerm2 as the default terminal: %v\n", err)
+		fmt.Printf("❌ Error setting iTerm2 as the default terminal: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ iTerm2 set as the default terminal. Please restart your shell.\n")
+	fmt.Printf("✅ iTerm2 set as the default terminal. Please restart your shell.\n")
 }
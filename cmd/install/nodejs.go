@@ -7,7 +7,6 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 type NodeJSInstaller struct{}
@@ -21,36 +20,15 @@ func (n *NodeJSInstaller) Install() {
 
 	fmt.Println("NVM is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing NVM %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install NVM using Homebrew
 	cmd := exec.Command("brew", "install", "nvm")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing NVM", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing NVM: %v\n", err)
+		fmt.Printf("❌ Error installing NVM: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ NVM installed successfully.\n")
+	fmt.Printf("✅ NVM installed successfully.\n")
 
 	// Install the latest LTS version of Node.js
 	n.installLatestLTSNode()
@@ -87,11 +65,11 @@ func (n *NodeJSInstaller) installLatestLTSNode() {
 	err := cmd.Run()
 
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Node.js: %v\n", err)
+		fmt.Printf("❌ Error installing Node.js: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Node.js installed successfully.\n")
+	fmt.Printf("✅ Node.js installed successfully.\n")
 }
 
 func (n *NodeJSInstaller) setNvmVariables() {
@@ -103,7 +81,7 @@ func (n *NodeJSInstaller) setNvmVariables() {
 	// Get the user's home directory
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return
 	}
 
@@ -131,16 +109,16 @@ export NVM_DIR="$HOME/.nvm"
 	// Append the NVM initialization script to the shell configuration file
 	f, err := os.OpenFile(configFile, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		fmt.Printf("\r❌ Error opening shell configuration file: %v\n", err)
+		fmt.Printf("❌ Error opening shell configuration file: %v\n", err)
 		return
 	}
 	defer f.Close()
 
 	_, err = f.WriteString(nvmScript)
 	if err != nil {
-		fmt.Printf("\r❌ Error writing to shell configuration file: %v\n", err)
+		fmt.Printf("❌ Error writing to shell configuration file: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ NVM environment variables set successfully. Please restart your shell.\n")
+	fmt.Printf("✅ NVM environment variables set successfully. Please restart your shell.\n")
 }
@@ -7,7 +7,6 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 type PythonInstaller struct{}
@@ -21,36 +20,15 @@ func (p *PythonInstaller) Install() {
 
 	fmt.Println("Python is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Python %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install Python using Homebrew
 	cmd := exec.Command("brew", "install", "python")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing Python", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Python: %v\n", err)
+		fmt.Printf("❌ Error installing Python: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Python installed successfully.\n")
+	fmt.Printf("✅ Python installed successfully.\n")
 
 	// Install Pyenv
 	p.installPyenv()
@@ -90,11 +68,11 @@ func (p *PythonInstaller) installPyenv() {
 	err := cmd.Run()
 
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Pyenv: %v\n", err)
+		fmt.Printf("❌ Error installing Pyenv: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Pyenv installed successfully.\n")
+	fmt.Printf("✅ Pyenv installed successfully.\n")
 }
 
 func (p *PythonInstaller) setPyenvVariables() {
@@ -103,7 +81,7 @@ func (p *PythonInstaller) setPyenvVariables() {
 	// Get the user's home directory
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return
 	}
 
@@ -125,7 +103,7 @@ func (p *PythonInstaller) setPyenvVariables() {
 	cmd := exec.Command("bash", "-c", fmt.Sprintf(`grep -q -F 'pyenv' %s`, configFile))
 	err = cmd.Run()
 	if err == nil {
-		fmt.Printf("\r✅ Pyenv environment variables set successfully. Please restart your shell.\n")
+		fmt.Printf("✅ Pyenv environment variables set successfully. Please restart your shell.\n")
 		return
 	}
 
@@ -143,7 +121,7 @@ eval "$(pyenv init -)"`
 
 	err = cmd.Run()
 	if err != nil {
-		fmt.Printf("\r❌ Error setting Pyenv environment variables: %v\n", err)
+		fmt.Printf("❌ Error setting Pyenv environment variables: %v\n", err)
 		return
 	}
 
@@ -153,11 +131,11 @@ eval "$(pyenv init -)"`
 
 	err = cmd.Run()
 	if err != nil {
-		fmt.Printf("\r❌ Error setting Pyenv environment variables: %v\n", err)
+		fmt.Printf("❌ Error setting Pyenv environment variables: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Pyenv environment variables set successfully. Please restart your shell.\n")
+	fmt.Printf("✅ Pyenv environment variables set successfully. Please restart your shell.\n")
 }
 
 func (p *PythonInstaller) installLatestPython() {
@@ -166,7 +144,7 @@ func (p *PythonInstaller) installLatestPython() {
 	// reload shell configuration
 	err := reloadShellConfiguration()
 	if err != nil {
-		fmt.Printf("\r❌ Error reloading shell configuration: %v\n", err)
+		fmt.Printf("❌ Error reloading shell configuration: %v\n", err)
 		return
 	}
 
@@ -178,9 +156,9 @@ func (p *PythonInstaller) installLatestPython() {
 	err = cmd.Run()
 
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Python: %v\n", err)
+		fmt.Printf("❌ Error installing Python: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Python installed successfully.\n")
+	fmt.Printf("✅ Python installed successfully.\n")
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"time"
 )
 
 type VscodeInstaller struct{}
@@ -17,36 +16,15 @@ func (v *VscodeInstaller) Install() {
 	}
 	fmt.Println("Visual Studio Code is not installed. Installing...")
 
-	// Display an animated hourglass loading indicator
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			default:
-				fmt.Printf("\r⌛ Installing Visual Studio Code %s", loadingAnimation())
-				time.Sleep(300 * time.Millisecond)
-			}
-		}
-	}()
-
 	// Install Visual Studio Code using Homebrew
 	cmd := exec.Command("brew", "install", "--cask", "visual-studio-code")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	err := cmd.Run()
-
-	// Stop the loading animation
-	close(done)
-
+	err := runWithProgress("Installing Visual Studio Code", cmd)
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Visual Studio Code: %v\n", err)
+		fmt.Printf("❌ Error installing Visual Studio Code: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Visual Studio Code installed successfully.\n")
+	fmt.Printf("✅ Visual Studio Code installed successfully.\n")
 
 	// Perform post-installation configurations
 	v.postInstall()
@@ -86,9 +64,9 @@ func (v *VscodeInstaller) setGitEditor() {
 	err := cmd.Run()
 
 	if err != nil {
-		fmt.Printf("\r❌ Error setting Git editor: %v\n", err)
+		fmt.Printf("❌ Error setting Git editor: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Visual Studio Code set as the default Git editor.\n")
+	fmt.Printf("✅ Visual Studio Code set as the default Git editor.\n")
 }
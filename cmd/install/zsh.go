@@ -8,7 +8,6 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 type ZshInstaller struct{}
@@ -32,7 +31,7 @@ func (z *ZshInstaller) Install() {
 	}
 
 	if err := z.Apply(); err != nil {
-		fmt.Printf("\r❌ Error installing Zsh: %v\n", err)
+		fmt.Printf("❌ Error installing Zsh: %v\n", err)
 	}
 }
 
@@ -50,33 +49,15 @@ func (z *ZshInstaller) Apply() error {
 	if !z.IsAlreadyInstalled() {
 		fmt.Println("Zsh is not installed. Installing...")
 
-		// Display an animated hourglass loading indicator
-		done := make(chan bool)
-		go func() {
-			for {
-				select {
-				case <-done:
-					return
-				default:
-					fmt.Printf("\r⌛ Installing Zsh %s", loadingAnimation())
-					time.Sleep(300 * time.Millisecond)
-				}
-			}
-		}()
-
 		cmd := exec.Command("brew", "install", "zsh")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		err := cmd.Run()
-		close(done)
+		err := runWithProgress("Installing Zsh", cmd)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("\r✅ Zsh installed successfully.\n")
+		fmt.Printf("✅ Zsh installed successfully.\n")
 	} else {
-		fmt.Printf("\r✅ Zsh is already installed.\n")
+		fmt.Printf("✅ Zsh is already installed.\n")
 	}
 
 	z.setDefaultShell()
@@ -143,12 +124,12 @@ func (z *ZshInstaller) setDefaultShell() {
 
 	path, err := detectValidZshShellPath()
 	if err != nil {
-		fmt.Printf("\r⚠️ Skipping default shell change: %v\n", err)
+		fmt.Printf("⚠️ Skipping default shell change: %v\n", err)
 		return
 	}
 
 	if os.Getenv("SHELL") == path {
-		fmt.Printf("\r✅ Zsh is already the active shell.\n")
+		fmt.Printf("✅ Zsh is already the active shell.\n")
 		return
 	}
 
@@ -158,11 +139,11 @@ func (z *ZshInstaller) setDefaultShell() {
 
 	err = cmd.Run()
 	if err != nil {
-		fmt.Printf("\r❌ Error setting Zsh as the default shell: %v\n", err)
+		fmt.Printf("❌ Error setting Zsh as the default shell: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Zsh set as the default shell.\n")
+	fmt.Printf("✅ Zsh set as the default shell.\n")
 }
 
 func detectValidZshShellPath() (string, error) {
@@ -183,13 +164,13 @@ func (z *ZshInstaller) installOhMyZsh() {
 
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return
 	}
 
 	ohMyZshDir := filepath.Join(usr.HomeDir, ".oh-my-zsh")
 	if _, err := os.Stat(ohMyZshDir); err == nil {
-		fmt.Printf("\r✅ Oh My Zsh is already installed.\n")
+		fmt.Printf("✅ Oh My Zsh is already installed.\n")
 		return
 	}
 
@@ -200,11 +181,11 @@ func (z *ZshInstaller) installOhMyZsh() {
 
 	err = cmd.Run()
 	if err != nil {
-		fmt.Printf("\r❌ Error installing Oh My Zsh: %v\n", err)
+		fmt.Printf("❌ Error installing Oh My Zsh: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ Oh My Zsh installed successfully.\n")
+	fmt.Printf("✅ Oh My Zsh installed successfully.\n")
 }
 
 func (z *ZshInstaller) postInstall() {
@@ -218,25 +199,25 @@ func (z *ZshInstaller) installZshPlugins() {
 
 	customPath, err := resolveZshCustomPath()
 	if err != nil {
-		fmt.Printf("\r❌ Error resolving ZSH_CUSTOM path: %v\n", err)
+		fmt.Printf("❌ Error resolving ZSH_CUSTOM path: %v\n", err)
 		return
 	}
 
 	pluginsDir := filepath.Join(customPath, "plugins")
 	if err := os.MkdirAll(pluginsDir, 0o755); err != nil {
-		fmt.Printf("\r❌ Error creating plugin directory: %v\n", err)
+		fmt.Printf("❌ Error creating plugin directory: %v\n", err)
 		return
 	}
 
 	z.clonePluginIfMissing("https://github.com/zsh-users/zsh-autosuggestions.git", filepath.Join(pluginsDir, "zsh-autosuggestions"))
 	z.clonePluginIfMissing("https://github.com/zsh-users/zsh-syntax-highlighting.git", filepath.Join(pluginsDir, "zsh-syntax-highlighting"))
 
-	fmt.Printf("\r✅ Zsh plugins installed successfully.\n")
+	fmt.Printf("✅ Zsh plugins installed successfully.\n")
 }
 
 func (z *ZshInstaller) clonePluginIfMissing(repo, destination string) {
 	if _, err := os.Stat(destination); err == nil {
-		fmt.Printf("\r✅ Plugin already installed: %s\n", filepath.Base(destination))
+		fmt.Printf("✅ Plugin already installed: %s\n", filepath.Base(destination))
 		return
 	}
 
@@ -244,7 +225,7 @@ func (z *ZshInstaller) clonePluginIfMissing(repo, destination string) {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("\r❌ Error installing %s: %v\n", filepath.Base(destination), err)
+		fmt.Printf("❌ Error installing %s: %v\n", filepath.Base(destination), err)
 	}
 }
 
@@ -275,34 +256,34 @@ func (z *ZshInstaller) updateZshrc() {
 
 	usr, err := user.Current()
 	if err != nil {
-		fmt.Printf("\r❌ Error getting user's home directory: %v\n", err)
+		fmt.Printf("❌ Error getting user's home directory: %v\n", err)
 		return
 	}
 
 	zshrcPath := filepath.Join(usr.HomeDir, ".zshrc")
 	content, err := os.ReadFile(zshrcPath)
 	if err != nil && !os.IsNotExist(err) {
-		fmt.Printf("\r❌ Error reading .zshrc file: %v\n", err)
+		fmt.Printf("❌ Error reading .zshrc file: %v\n", err)
 		return
 	}
 
 	if strings.Contains(string(content), "# >>> go-env-prepare zsh >>>") {
-		fmt.Printf("\r✅ .zshrc already contains go-env-prepare snippet.\n")
+		fmt.Printf("✅ .zshrc already contains go-env-prepare snippet.\n")
 		return
 	}
 
 	f, err := os.OpenFile(zshrcPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
 	if err != nil {
-		fmt.Printf("\r❌ Error opening .zshrc file: %v\n", err)
+		fmt.Printf("❌ Error opening .zshrc file: %v\n", err)
 		return
 	}
 	defer f.Close()
 
 	_, err = f.WriteString(zshrcManagedBlock)
 	if err != nil {
-		fmt.Printf("\r❌ Error writing to .zshrc file: %v\n", err)
+		fmt.Printf("❌ Error writing to .zshrc file: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\r✅ .zshrc file updated successfully.\n")
+	fmt.Printf("✅ .zshrc file updated successfully.\n")
 }
@@ -8,7 +8,7 @@ import (
 func Execute() {
 	commands := NewCommands()
 	if err := commands.RootCmd.Execute(); err != nil {
-		fmt.Println("Error:", err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
 		os.Exit(1)
 	}
 }
@@ -1,5 +1,10 @@
 package dynamic
 
+import (
+	"fmt"
+	"sort"
+)
+
 func BuiltinProfiles() map[string]Profile {
 	return map[string]Profile{
 		"base": {
@@ -35,11 +40,15 @@ func BuiltinCatalog() map[string]ToolSpec {
 			Title:       "Homebrew",
 			Description: "Package manager for macOS",
 			Install: Command{
-				Name:  "/bin/bash",
-				Args:  []string{"-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"},
-				Shell: "bash",
+				Name:  `/bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"`,
+				Shell: "/bin/bash",
+			},
+			Uninstall: &Command{
+				Name:  `/bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/uninstall.sh)"`,
+				Shell: "/bin/bash",
 			},
-			Check:   Check{Binary: "brew"},
+			Upgrade: brewCommand("update"),
+			Check:   Check{Binary: "brew", Version: versionProbe(`Homebrew (\d+\.\d+\.\d+)`, "brew", "--version")},
 			Version: "latest",
 			Source:  "homebrew/homebrew-core",
 		},
@@ -49,59 +58,78 @@ func BuiltinCatalog() map[string]ToolSpec {
 			Description:  "Terminal emulator",
 			Dependencies: []string{"homebrew"},
 			Install:      Command{Name: "brew", Args: []string{"install", "--cask", "iterm2"}},
-			Check:        Check{PathExists: "/Applications/iTerm.app"},
+			Uninstall:    brewCommand("uninstall", "--cask", "iterm2"),
+			Upgrade:      brewCommand("upgrade", "--cask", "iterm2"),
+			Check:        Check{PathExists: "/Applications/iTerm.app", Version: versionProbe(`(\d+\.\d+(?:\.\d+)?)`, "defaults", "read", "/Applications/iTerm.app/Contents/Info.plist", "CFBundleShortVersionString")},
 			Version:      "latest",
 			Source:       "homebrew/cask",
 		},
+		// Only the shell itself: this entry does not set up Oh My Zsh, so the
+		// legacy installer's .zshrc and plugin checks are left out of its check.
 		"zsh": {
-			ID:           "zsh",
-			Title:        "Zsh",
-			Description:  "Shell",
-			Dependencies: []string{"homebrew"},
-			Install:      Command{Name: "brew", Args: []string{"install", "zsh"}},
-			Check:        Check{Binary: "zsh"},
-			Version:      "latest",
-			Source:       "homebrew/homebrew-core",
+			ID:            "zsh",
+			Title:         "Zsh",
+			Description:   "Shell",
+			Dependencies:  []string{"homebrew"},
+			Install:       Command{Name: "brew", Args: []string{"install", "zsh"}},
+			Uninstall:     brewCommand("uninstall", "zsh"),
+			Upgrade:       brewCommand("upgrade", "zsh"),
+			PinnedInstall: brewPinnedInstall("zsh"),
+			Check:         Check{Binary: "zsh", Version: versionProbe(`zsh (\d+\.\d+(?:\.\d+)?)`, "zsh", "--version")},
+			Version:       "latest",
+			Source:        "homebrew/homebrew-core",
 		},
 		"vscode": {
 			ID:           "vscode",
 			Title:        "Visual Studio Code",
 			Description:  "Code editor",
-			Dependencies: []string{"homebrew"},
+			Dependencies: []string{"homebrew", "git"},
 			Install:      Command{Name: "brew", Args: []string{"install", "--cask", "visual-studio-code"}},
-			Check:        Check{Binary: "code"},
+			Uninstall:    brewCommand("uninstall", "--cask", "visual-studio-code"),
+			Upgrade:      brewCommand("upgrade", "--cask", "visual-studio-code"),
+			PostInstall:  []Command{{Name: "git", Args: []string{"config", "--global", "core.editor", "code --wait"}}},
+			Check:        Check{Binary: "code", Version: versionProbe(`^(\d+\.\d+\.\d+)`, "code", "--version")},
 			Version:      "latest",
 			Source:       "homebrew/cask",
 		},
 		"git": {
-			ID:           "git",
-			Title:        "Git",
-			Description:  "Version control",
-			Dependencies: []string{"homebrew"},
-			Install:      Command{Name: "brew", Args: []string{"install", "git"}},
-			Check:        Check{Binary: "git"},
-			Version:      "latest",
-			Source:       "homebrew/homebrew-core",
+			ID:            "git",
+			Title:         "Git",
+			Description:   "Version control",
+			Dependencies:  []string{"homebrew"},
+			Install:       Command{Name: "brew", Args: []string{"install", "git"}},
+			Uninstall:     brewCommand("uninstall", "git"),
+			Upgrade:       brewCommand("upgrade", "git"),
+			PinnedInstall: brewPinnedInstall("git"),
+			Check:         Check{Binary: "git", Version: versionProbe(`git version (\d+\.\d+\.\d+)`, "git", "--version")},
+			Version:       "latest",
+			Source:        "homebrew/homebrew-core",
 		},
 		"go": {
-			ID:           "go",
-			Title:        "Go",
-			Description:  "Go programming language",
-			Dependencies: []string{"homebrew"},
-			Install:      Command{Name: "brew", Args: []string{"install", "go"}},
-			Check:        Check{Binary: "go"},
-			Version:      "latest",
-			Source:       "homebrew/homebrew-core",
+			ID:            "go",
+			Title:         "Go",
+			Description:   "Go programming language",
+			Dependencies:  []string{"homebrew"},
+			Install:       Command{Name: "brew", Args: []string{"install", "go"}},
+			Uninstall:     brewCommand("uninstall", "go"),
+			Upgrade:       brewCommand("upgrade", "go"),
+			PinnedInstall: brewPinnedInstall("go"),
+			Check:         Check{Binary: "go", Version: versionProbe(`go(\d+\.\d+(?:\.\d+)?)`, "go", "version")},
+			Version:       "latest",
+			Source:        "homebrew/homebrew-core",
 		},
 		"nodejs": {
-			ID:           "nodejs",
-			Title:        "Node.js",
-			Description:  "Node.js LTS runtime",
-			Dependencies: []string{"homebrew"},
-			Install:      Command{Name: "brew", Args: []string{"install", "node"}},
-			Check:        Check{Binary: "node"},
-			Version:      "lts",
-			Source:       "homebrew/homebrew-core",
+			ID:            "nodejs",
+			Title:         "Node.js",
+			Description:   "Node.js LTS runtime",
+			Dependencies:  []string{"homebrew"},
+			Install:       Command{Name: "brew", Args: []string{"install", "node"}},
+			Uninstall:     brewCommand("uninstall", "node"),
+			Upgrade:       brewCommand("upgrade", "node"),
+			PinnedInstall: brewPinnedInstall("node"),
+			Check:         nodeCheck(),
+			Version:       "lts",
+			Source:        "homebrew/homebrew-core",
 		},
 		"dotnet": {
 			ID:           "dotnet",
@@ -109,7 +137,9 @@ func BuiltinCatalog() map[string]ToolSpec {
 			Description:  ".NET SDK",
 			Dependencies: []string{"homebrew"},
 			Install:      Command{Name: "brew", Args: []string{"install", "dotnet-sdk"}},
-			Check:        Check{Binary: "dotnet"},
+			Uninstall:    brewCommand("uninstall", "dotnet-sdk"),
+			Upgrade:      brewCommand("upgrade", "dotnet-sdk"),
+			Check:        Check{Binary: "dotnet", Version: versionProbe(`^(\d+\.\d+\.\d+)`, "dotnet", "--version")},
 			Version:      "latest",
 			Source:       "homebrew/homebrew-core",
 		},
@@ -119,19 +149,204 @@ func BuiltinCatalog() map[string]ToolSpec {
 			Description:  "Python runtime",
 			Dependencies: []string{"homebrew"},
 			Install:      Command{Name: "brew", Args: []string{"install", "python"}},
-			Check:        Check{Binary: "python3"},
+			Uninstall:    brewCommand("uninstall", "python"),
+			Upgrade:      brewCommand("upgrade", "python"),
+			Check:        Check{Binary: "python3", Version: versionProbe(`Python (\d+\.\d+\.\d+)`, "python3", "--version")},
 			Version:      "latest",
 			Source:       "homebrew/homebrew-core",
 		},
 		"docker": {
-			ID:           "docker",
-			Title:        "Docker",
-			Description:  "Container runtime",
-			Dependencies: []string{"homebrew"},
-			Install:      Command{Name: "brew", Args: []string{"install", "docker"}},
-			Check:        Check{Binary: "docker"},
-			Version:      "latest",
-			Source:       "homebrew/homebrew-core",
+			ID:            "docker",
+			Title:         "Docker",
+			Description:   "Container runtime",
+			Dependencies:  []string{"homebrew"},
+			Install:       Command{Name: "brew", Args: []string{"install", "docker"}},
+			Uninstall:     brewCommand("uninstall", "docker"),
+			Upgrade:       brewCommand("upgrade", "docker"),
+			PinnedInstall: brewPinnedInstall("docker"),
+			Check:         Check{Binary: "docker", Version: versionProbe(`version (\d+\.\d+\.\d+)`, "docker", "--version")},
+			Version:       "latest",
+			Source:        "homebrew/homebrew-core",
+		},
+	}
+}
+
+func brewCommand(args ...string) *Command {
+	return &Command{Name: "brew", Args: args}
+}
+
+// pinnedTap is the local tap exact formula versions are extracted into.
+const pinnedTap = "prepare/pinned"
+
+// brewPinnedInstall installs an exact version of a homebrew-core formula by
+// extracting it into pinnedTap, as Homebrew has no other way to install a
+// version it no longer ships.
+func brewPinnedInstall(formula string) *Command {
+	script := `set -e
+[ -d "$(brew --repository)/Library/Taps/prepare/homebrew-pinned" ] || brew tap-new --no-git ` + pinnedTap + `
+brew extract --force --version="$2" "$1" ` + pinnedTap + `
+brew install "` + pinnedTap + `/$1@$2"`
+	return &Command{Name: script, Shell: "/bin/bash", Args: []string{formula, "${version}"}}
+}
+
+// nodeCheck also accepts a node managed by nvm, as the legacy installer's
+// NVM_DIR check did, once nvm has a node version in place.
+func nodeCheck() Check {
+	return Check{
+		Any: []Check{
+			{Binary: "node"},
+			{All: []Check{{EnvSet: "NVM_DIR"}, {DirNotEmpty: "$NVM_DIR/versions/node"}}},
 		},
+		Version: versionProbe(`v(\d+\.\d+\.\d+)`, "node", "--version"),
+	}
+}
+
+func versionProbe(pattern string, name string, args ...string) *VersionProbe {
+	return &VersionProbe{Command: Command{Name: name, Args: args}, Pattern: pattern}
+}
+
+// MergeCatalog layers overrides over base. Overrides for known tools only
+// replace the fields they set; unknown tools are added as declared.
+func MergeCatalog(base map[string]ToolSpec, overrides map[string]ToolSpec) map[string]ToolSpec {
+	merged := make(map[string]ToolSpec, len(base)+len(overrides))
+	for id, spec := range base {
+		merged[id] = spec
+	}
+	for id, override := range overrides {
+		if override.ID == "" {
+			override.ID = id
+		}
+		if spec, ok := merged[id]; ok {
+			merged[id] = mergeToolSpec(spec, override)
+			continue
+		}
+		merged[id] = override
+	}
+	return merged
+}
+
+func mergeToolSpec(base ToolSpec, override ToolSpec) ToolSpec {
+	out := base
+	if override.Title != "" {
+		out.Title = override.Title
+	}
+	if override.Description != "" {
+		out.Description = override.Description
+	}
+	if override.Dependencies != nil {
+		out.Dependencies = append([]string{}, override.Dependencies...)
+	}
+	if override.Install.Name != "" {
+		out.Install = override.Install
+	}
+	if override.PinnedInstall != nil {
+		out.PinnedInstall = override.PinnedInstall
+	}
+	if override.Uninstall != nil {
+		out.Uninstall = override.Uninstall
+	}
+	if override.Upgrade != nil {
+		out.Upgrade = override.Upgrade
+	}
+	if override.PreInstall != nil {
+		out.PreInstall = append([]Command{}, override.PreInstall...)
+	}
+	if override.PostInstall != nil {
+		out.PostInstall = append([]Command{}, override.PostInstall...)
+	}
+	if !isZeroCheck(override.Check) {
+		out.Check = override.Check
+	}
+	if override.Version != "" {
+		out.Version = override.Version
+	}
+	if override.Constraint != "" {
+		out.Constraint = override.Constraint
+	}
+	if override.Source != "" {
+		out.Source = override.Source
+	}
+	if override.Lock != "" {
+		out.Lock = override.Lock
+	}
+	if override.Retry != nil {
+		out.Retry = override.Retry
+	}
+	if override.Timeout != "" {
+		out.Timeout = override.Timeout
+	}
+	return out
+}
+
+func validateToolSpec(id string, declared ToolSpec, catalog map[string]ToolSpec) error {
+	if declared.ID != "" && declared.ID != id {
+		return fmt.Errorf("tool %q declares mismatched id %q", id, declared.ID)
+	}
+	spec := catalog[id]
+	if spec.Install.Name == "" {
+		return fmt.Errorf("tool %q has no install command", id)
+	}
+	if isZeroCheck(spec.Check) {
+		return fmt.Errorf("tool %q has no check", id)
+	}
+	if err := validateCheck(spec.Check); err != nil {
+		return fmt.Errorf("tool %q: %w", id, err)
+	}
+	for _, dep := range spec.Dependencies {
+		if _, ok := catalog[dep]; !ok {
+			return fmt.Errorf("tool %q depends on unknown tool %q", id, dep)
+		}
+	}
+	for name, c := range toolCommands(spec) {
+		if err := validateCommand(c); err != nil {
+			return fmt.Errorf("tool %q: %s: %w", id, name, err)
+		}
+	}
+	if spec.Retry != nil {
+		if err := spec.Retry.validate(); err != nil {
+			return fmt.Errorf("tool %q: %w", id, err)
+		}
+	}
+	if _, err := parseTimeout(spec.Timeout); err != nil {
+		return fmt.Errorf("tool %q: %w", id, err)
+	}
+	if spec.Constraint != "" {
+		if _, err := ParseConstraint(spec.Constraint); err != nil {
+			return fmt.Errorf("tool %q: %w", id, err)
+		}
+		if spec.Check.Version == nil {
+			return fmt.Errorf("tool %q has a version constraint but no version probe", id)
+		}
+	}
+	return nil
+}
+
+// toolCommands returns every command a tool may run, keyed by its role.
+func toolCommands(spec ToolSpec) map[string]Command {
+	commands := map[string]Command{"install": spec.Install}
+	if spec.Uninstall != nil {
+		commands["uninstall"] = *spec.Uninstall
+	}
+	if spec.Upgrade != nil {
+		commands["upgrade"] = *spec.Upgrade
+	}
+	if spec.PinnedInstall != nil {
+		commands["pinnedInstall"] = *spec.PinnedInstall
+	}
+	for i, hook := range spec.PreInstall {
+		commands[fmt.Sprintf("preInstall[%d]", i)] = hook
+	}
+	for i, hook := range spec.PostInstall {
+		commands[fmt.Sprintf("postInstall[%d]", i)] = hook
+	}
+	return commands
+}
+
+func SortedToolIDs(catalog map[string]ToolSpec) []string {
+	ids := make([]string, 0, len(catalog))
+	for id := range catalog {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
+	return ids
 }
@@ -0,0 +1,114 @@
+package dynamic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadCatalog layers the tool definitions found at paths over base and
+// returns the resulting catalog.
+//
+// Each path is either a definition file or a directory of them (one ToolSpec
+// per .json/.yaml/.yml file, not recursive). A definition replaces any tool of
+// the same ID from base or from an earlier path; IDs repeated within a single
+// path are rejected. Every problem is reported, prefixed with its file.
+func LoadCatalog(base map[string]ToolSpec, paths []string) (map[string]ToolSpec, error) {
+	catalog := MergeCatalog(base, nil)
+	origin := map[string]string{}
+	var errs []error
+
+	for _, path := range paths {
+		files, err := catalogFiles(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		definedHere := map[string]string{}
+		for _, file := range files {
+			spec, err := loadToolDefinition(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", file, err))
+				continue
+			}
+			if prev, ok := definedHere[spec.ID]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate tool id %q (already defined in %s)", file, spec.ID, prev))
+				continue
+			}
+			definedHere[spec.ID] = file
+			origin[spec.ID] = file
+			catalog[spec.ID] = spec
+		}
+	}
+
+	for _, id := range SortedToolIDs(catalog) {
+		file, ok := origin[id]
+		if !ok {
+			continue
+		}
+		if err := validateToolSpec(id, catalog[id], catalog); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return catalog, nil
+}
+
+func catalogFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", path, err)
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isDefinitionFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	return files, nil
+}
+
+func isDefinitionFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loadToolDefinition reads one ToolSpec. The ID defaults to the file name
+// without its extension.
+func loadToolDefinition(path string) (ToolSpec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ToolSpec{}, err
+	}
+	var spec ToolSpec
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return ToolSpec{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else if err := decodeYAMLDocument(b, &spec); err != nil {
+		return ToolSpec{}, err
+	}
+	if spec.ID == "" {
+		spec.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return spec, nil
+}
@@ -0,0 +1,58 @@
+package dynamic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCatalogFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestLoadCatalogLayersDefinitionsOverBase(t *testing.T) {
+	dir := t.TempDir()
+	writeCatalogFile(t, dir, "acme.yaml", "title: Acme CLI\ndependencies: [go]\ninstall:\n  name: go\n  args: [install, example.com/acme@latest]\ncheck:\n  binary: acme\n")
+	writeCatalogFile(t, dir, "git.json", `{"id": "git", "install": {"name": "brew", "args": ["install", "git"]}, "check": {"binary": "git"}, "version": "2.44.0"}`)
+	writeCatalogFile(t, dir, "README.md", "not a definition")
+
+	catalog, err := LoadCatalog(BuiltinCatalog(), []string{dir})
+	if err != nil {
+		t.Fatalf("LoadCatalog error: %v", err)
+	}
+	if spec := catalog["acme"]; spec.ID != "acme" || spec.Check.Binary != "acme" {
+		t.Fatalf("unexpected acme definition: %#v", spec)
+	}
+	if spec := catalog["git"]; spec.Version != "2.44.0" || spec.Title != "" {
+		t.Fatalf("expected git definition to replace builtin entry, got %#v", spec)
+	}
+	if _, ok := catalog["homebrew"]; !ok {
+		t.Fatal("expected builtin tools to remain in catalog")
+	}
+}
+
+func TestLoadCatalogReportsEveryInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writeCatalogFile(t, dir, "a.yaml", "id: dup\ninstall: {name: true}\ncheck: {binary: dup}\n")
+	writeCatalogFile(t, dir, "b.yaml", "id: dup\ninstall: {name: true}\ncheck: {binary: dup}\n")
+	writeCatalogFile(t, dir, "nocheck.yaml", "install: {name: true}\n")
+	writeCatalogFile(t, dir, "orphan.json", `{"install": {"name": "true"}, "check": {"binary": "orphan"}, "dependencies": ["missing"]}`)
+
+	_, err := LoadCatalog(BuiltinCatalog(), []string{dir})
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		filepath.Join(dir, "b.yaml") + `: duplicate tool id "dup"`,
+		filepath.Join(dir, "nocheck.yaml") + `: tool "nocheck" has no check`,
+		filepath.Join(dir, "orphan.json") + `: tool "orphan" depends on unknown tool "missing"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds every check and version probe command, so a hung probe
+// fails instead of blocking run, list and verify.
+var probeTimeout = 30 * time.Second
+
+// runProbe runs a check or version probe command under probeTimeout and
+// returns what collect captured.
+func runProbe(c Command, collect func(*exec.Cmd) ([]byte, error)) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	cmd := newExecCmd(ctx, c)
+	// Do not wait on pipes held open by children of a killed probe.
+	cmd.WaitDelay = time.Second
+	out, err := collect(cmd)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s timed out after %s", c.Name, probeTimeout)
+	}
+	return out, err
+}
+
+func defaultChecker(c Check) bool {
+	leaves := leafChecks(c)
+	if len(leaves) > 0 {
+		matched := false
+		for _, leaf := range leaves {
+			if leaf() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, sub := range c.All {
+		if !defaultChecker(sub) {
+			return false
+		}
+	}
+	if len(c.Any) > 0 {
+		matched := false
+		for _, sub := range c.Any {
+			if defaultChecker(sub) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.Not != nil && defaultChecker(*c.Not) {
+		return false
+	}
+	return len(leaves) > 0 || len(c.All) > 0 || len(c.Any) > 0 || c.Not != nil
+}
+
+// leafChecks returns a probe for every leaf condition set on c.
+func leafChecks(c Check) []func() bool {
+	leaves := []func() bool{}
+	if c.Binary != "" {
+		leaves = append(leaves, func() bool {
+			_, err := exec.LookPath(c.Binary)
+			return err == nil
+		})
+	}
+	if c.PathExists != "" {
+		leaves = append(leaves, func() bool {
+			_, err := os.Stat(expandCheckPath(c.PathExists))
+			return err == nil
+		})
+	}
+	if c.EnvSet != "" {
+		leaves = append(leaves, func() bool {
+			_, ok := os.LookupEnv(c.EnvSet)
+			return ok
+		})
+	}
+	if c.FileContains != nil {
+		leaves = append(leaves, func() bool {
+			content, err := os.ReadFile(expandCheckPath(c.FileContains.Path))
+			return err == nil && strings.Contains(string(content), c.FileContains.Text)
+		})
+	}
+	if c.Command != nil {
+		leaves = append(leaves, func() bool {
+			cmd, err := expandCommand(*c.Command, nil)
+			if err != nil {
+				return false
+			}
+			_, err = runProbe(cmd, (*exec.Cmd).Output)
+			return err == nil
+		})
+	}
+	if c.OutputMatches != nil {
+		leaves = append(leaves, func() bool {
+			pattern, err := regexp.Compile(c.OutputMatches.Pattern)
+			if err != nil {
+				return false
+			}
+			cmd, err := expandCommand(c.OutputMatches.Command, nil)
+			if err != nil {
+				return false
+			}
+			out, err := runProbe(cmd, (*exec.Cmd).Output)
+			return err == nil && pattern.Match(out)
+		})
+	}
+	if c.DirNotEmpty != "" {
+		leaves = append(leaves, func() bool {
+			entries, err := os.ReadDir(expandCheckPath(c.DirNotEmpty))
+			return err == nil && len(entries) > 0
+		})
+	}
+	return leaves
+}
+
+// expandCheckPath expands environment variables and a leading "~/" so checks
+// can refer to files such as "~/.zshrc" or "$ZSH_CUSTOM/plugins".
+func expandCheckPath(path string) string {
+	return expandHome(os.ExpandEnv(path))
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+func isZeroCheck(c Check) bool {
+	c.Version = nil
+	return reflect.ValueOf(c).IsZero()
+}
+
+func validateCheck(c Check) error {
+	if c.FileContains != nil && (c.FileContains.Path == "" || c.FileContains.Text == "") {
+		return errors.New("fileContains check requires path and text")
+	}
+	if c.Command != nil && c.Command.Name == "" {
+		return errors.New("command check requires a name")
+	}
+	if c.OutputMatches != nil {
+		if c.OutputMatches.Command.Name == "" {
+			return errors.New("outputMatches check requires a command name")
+		}
+		if _, err := regexp.Compile(c.OutputMatches.Pattern); err != nil {
+			return fmt.Errorf("outputMatches check has an invalid pattern: %w", err)
+		}
+	}
+	if c.Version != nil {
+		if _, err := regexp.Compile(c.Version.Pattern); err != nil {
+			return fmt.Errorf("invalid version pattern: %w", err)
+		}
+	}
+	for _, sub := range append(append([]Check{}, c.All...), c.Any...) {
+		if isZeroCheck(sub) {
+			return errors.New("all/any checks must not be empty")
+		}
+		if err := validateCheck(sub); err != nil {
+			return err
+		}
+	}
+	if c.Not != nil {
+		if isZeroCheck(*c.Not) {
+			return errors.New("not check must not be empty")
+		}
+		return validateCheck(*c.Not)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package dynamic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultCheckerCompositeKinds(t *testing.T) {
+	dir := t.TempDir()
+	rc := filepath.Join(dir, ".zshrc")
+	if err := os.WriteFile(rc, []byte("# >>> go-env-prepare zsh >>>\n"), 0o644); err != nil {
+		t.Fatalf("write rc: %v", err)
+	}
+	plugins := filepath.Join(dir, "plugins")
+	if err := os.MkdirAll(filepath.Join(plugins, "zsh-autosuggestions"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Setenv("PREPARE_TEST_DIR", dir)
+	t.Setenv("PREPARE_TEST_SET", "1")
+
+	tests := []struct {
+		name  string
+		check Check
+		want  bool
+	}{
+		{"env set", Check{EnvSet: "PREPARE_TEST_SET"}, true},
+		{"env unset", Check{EnvSet: "PREPARE_TEST_UNSET"}, false},
+		{"file contains", Check{FileContains: &FileContains{Path: "$PREPARE_TEST_DIR/.zshrc", Text: "go-env-prepare zsh"}}, true},
+		{"file lacks", Check{FileContains: &FileContains{Path: rc, Text: "nvm"}}, false},
+		{"dir not empty", Check{DirNotEmpty: plugins}, true},
+		{"dir empty", Check{DirNotEmpty: filepath.Join(plugins, "zsh-autosuggestions")}, false},
+		{"command succeeds", Check{Command: &Command{Name: "true"}}, true},
+		{"command fails", Check{Command: &Command{Name: "false"}}, false},
+		{"output matches", Check{OutputMatches: &OutputMatch{Command: Command{Name: "echo", Args: []string{"v1.2.3"}}, Pattern: `^v1\.`}}, true},
+		{"leaves are or'ed", Check{EnvSet: "PREPARE_TEST_UNSET", DirNotEmpty: plugins}, true},
+		{"all", Check{All: []Check{{EnvSet: "PREPARE_TEST_SET"}, {DirNotEmpty: plugins}}}, true},
+		{"all fails", Check{All: []Check{{EnvSet: "PREPARE_TEST_SET"}, {EnvSet: "PREPARE_TEST_UNSET"}}}, false},
+		{"any", Check{Any: []Check{{EnvSet: "PREPARE_TEST_UNSET"}, {PathExists: rc}}}, true},
+		{"not", Check{Not: &Check{EnvSet: "PREPARE_TEST_UNSET"}}, true},
+		{"leaf and combinator", Check{EnvSet: "PREPARE_TEST_SET", Not: &Check{PathExists: rc}}, false},
+		{"version only", Check{Version: &VersionProbe{Command: Command{Name: "true"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultChecker(tt.check); got != tt.want {
+				t.Fatalf("defaultChecker() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbesTimeOut(t *testing.T) {
+	defer func(timeout time.Duration) { probeTimeout = timeout }(probeTimeout)
+	probeTimeout = 50 * time.Millisecond
+	hang := Command{Name: "sleep", Args: []string{"5"}}
+
+	start := time.Now()
+	if defaultChecker(Check{Command: &hang}) {
+		t.Fatal("hung command check passed")
+	}
+	if defaultChecker(Check{OutputMatches: &OutputMatch{Command: hang, Pattern: "."}}) {
+		t.Fatal("hung outputMatches check passed")
+	}
+	_, err := defaultVersionProber(VersionProbe{Command: hang, Pattern: "."})
+	if err == nil || !strings.Contains(err.Error(), "sleep timed out after 50ms") {
+		t.Fatalf("defaultVersionProber() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Fatalf("probes took %s, want them cut off at the timeout", elapsed)
+	}
+}
+
+func TestBuiltinNodeCheckAcceptsNVM(t *testing.T) {
+	check := BuiltinCatalog()["nodejs"].Check
+	nvm := t.TempDir()
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("NVM_DIR", nvm)
+	if defaultChecker(check) {
+		t.Fatal("nodejs check passed with an empty NVM_DIR")
+	}
+	if err := os.MkdirAll(filepath.Join(nvm, "versions", "node", "v20.11.1"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if !defaultChecker(check) {
+		t.Fatal("nodejs check failed with a node installed by nvm")
+	}
+}
+
+func TestLoadToolDefinitionDecodesNestedChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zsh-config.yaml")
+	content := `install: {name: "true"}
+check:
+  all:
+    - binary: zsh
+    - fileContains: {path: ~/.zshrc, text: "# >>> go-env-prepare zsh >>>"}
+    - not:
+        envSet: CI
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write definition: %v", err)
+	}
+	spec, err := loadToolDefinition(path)
+	if err != nil {
+		t.Fatalf("loadToolDefinition error: %v", err)
+	}
+	all := spec.Check.All
+	if len(all) != 3 || all[1].FileContains == nil || all[2].Not == nil || all[2].Not.EnvSet != "CI" {
+		t.Fatalf("unexpected nested check: %#v", spec.Check)
+	}
+	if err := validateCheck(Check{All: []Check{{}}}); err == nil {
+		t.Fatal("expected empty sub-check to be rejected")
+	}
+}
@@ -0,0 +1,173 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// toolVariables are the tool-provided values available to its commands as
+// ${id} and ${version}. Shell scripts see them as $PREPARE_TOOL_ID and
+// $PREPARE_TOOL_VERSION instead.
+func toolVariables(tool ToolSpec) map[string]string {
+	return map[string]string{"id": tool.ID, "version": tool.Version}
+}
+
+// expandCommand expands variables in the arguments, working directory and
+// environment values of c. A shell script (Name when Shell is set) and Stdin
+// are passed through untouched: the shell does its own expansion, and tool
+// values reach it through the environment rather than being spliced into
+// the script.
+func expandCommand(c Command, vars map[string]string) (Command, error) {
+	lookup := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	out := c
+	var err error
+	if c.Shell == "" {
+		if out.Name, err = expandVariables(c.Name, lookup); err != nil {
+			return Command{}, err
+		}
+	}
+	out.Args = make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		if out.Args[i], err = expandVariables(arg, lookup); err != nil {
+			return Command{}, err
+		}
+	}
+	if out.Dir, err = expandVariables(c.Dir, lookup); err != nil {
+		return Command{}, err
+	}
+	out.Dir = expandHome(out.Dir)
+	out.Env = make(map[string]string, len(c.Env)+2)
+	for key, value := range c.Env {
+		if out.Env[key], err = expandVariables(value, lookup); err != nil {
+			return Command{}, err
+		}
+	}
+	if c.Shell != "" {
+		if id, ok := vars["id"]; ok {
+			out.Env["PREPARE_TOOL_ID"] = id
+		}
+		if version, ok := vars["version"]; ok {
+			out.Env["PREPARE_TOOL_VERSION"] = version
+		}
+	}
+	return out, nil
+}
+
+// expandVariables replaces $NAME and ${NAME} in s. "$$" is a literal dollar
+// sign and a "$" not followed by a name is kept as is. Expansion is a single
+// pass over s: substituted values are never expanded again, split into
+// several arguments or interpreted by a shell. An undefined name is an error.
+func expandVariables(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		var name string
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			name = s[i+2 : i+2+end]
+			if !isVariableName(name) {
+				return "", fmt.Errorf("invalid variable name %q in %q", name, s)
+			}
+			i += end + 2
+		case isVariableStart(next):
+			j := i + 1
+			for j < len(s) && isVariableChar(s[j]) {
+				j++
+			}
+			name = s[i+1 : j]
+			i = j - 1
+		default:
+			b.WriteByte('$')
+			continue
+		}
+		value, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("undefined variable %q in %q", name, s)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+func isVariableName(name string) bool {
+	if name == "" || !isVariableStart(name[0]) {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if !isVariableChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVariableStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVariableChar(c byte) bool {
+	return isVariableStart(c) || (c >= '0' && c <= '9')
+}
+
+// newExecCmd builds the process for an expanded command. With Shell set,
+// Name is the script run by "<shell> -c" and Args become its positional
+// parameters ($1, $2, ...), so they are never parsed as shell code.
+func newExecCmd(ctx context.Context, c Command) *exec.Cmd {
+	var cmd *exec.Cmd
+	if c.Shell != "" {
+		cmd = exec.CommandContext(ctx, c.Shell, append([]string{"-c", c.Name, c.Shell}, c.Args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, c.Name, c.Args...)
+	}
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		keys := make([]string, 0, len(c.Env))
+		for key := range c.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		cmd.Env = os.Environ()
+		for _, key := range keys {
+			cmd.Env = append(cmd.Env, key+"="+c.Env[key])
+		}
+	}
+	if c.Stdin != "" {
+		cmd.Stdin = strings.NewReader(c.Stdin)
+	}
+	return cmd
+}
+
+func validateCommand(c Command) error {
+	if c.Name == "" {
+		return fmt.Errorf("command has no name")
+	}
+	for key := range c.Env {
+		if !isVariableName(key) {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	return nil
+}
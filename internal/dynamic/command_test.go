@@ -0,0 +1,97 @@
+package dynamic
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandVariables(t *testing.T) {
+	t.Setenv("PREPARE_TEST_HOME", "/Users/dev")
+	vars := map[string]string{"id": "go", "version": "1.22.1; rm -rf /"}
+	tests := []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: "go@${version}", want: "go@1.22.1; rm -rf /"},
+		{in: "$PREPARE_TEST_HOME/sdk/$id", want: "/Users/dev/sdk/go"},
+		{in: "cost: $$5 and $(not a var) and 100$", want: "cost: $5 and $(not a var) and 100$"},
+		{in: "$PREPARE_TEST_UNSET", err: `undefined variable "PREPARE_TEST_UNSET"`},
+		{in: "${version", err: "unterminated variable"},
+		{in: "${not-a-name}", err: "invalid variable name"},
+	}
+	for _, tt := range tests {
+		cmd, err := expandCommand(Command{Name: "echo", Args: []string{tt.in}}, vars)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expand %q: expected error containing %q, got %v", tt.in, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || len(cmd.Args) != 1 || cmd.Args[0] != tt.want {
+			t.Fatalf("expand %q: got %q (err %v), want %q", tt.in, cmd.Args, err, tt.want)
+		}
+	}
+}
+
+func TestDefaultCommandRunnerHonoursShellEnvDirAndStdin(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("requires /bin/sh")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	tool := ToolSpec{ID: "acme", Version: "2.0.0"}
+	c := Command{
+		Shell: "/bin/sh",
+		Name:  `read line; printf '%s|%s|%s|%s|%s' "$line" "$1" "$GREETING" "$PREPARE_TOOL_VERSION" "$(basename "$PWD")" > "$2"`,
+		Args:  []string{"a b; echo injected", out},
+		Env:   map[string]string{"GREETING": "hello ${id}"},
+		Dir:   dir,
+		Stdin: "from stdin\n",
+	}
+	expanded, err := expandCommand(c, toolVariables(tool))
+	if err != nil {
+		t.Fatalf("expandCommand error: %v", err)
+	}
+	if err := defaultCommandRunner(context.Background(), expanded, io.Discard); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	want := "from stdin|a b; echo injected|hello acme|2.0.0|" + filepath.Base(dir)
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestHomebrewScriptsRunTheDownloadedScript(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("requires /bin/bash")
+	}
+	// A stub curl prints a script that records which script it stands for.
+	bin := t.TempDir()
+	curl := "#!/bin/sh\nfor last; do :; done\nprintf 'echo \"ran %s\" > \"%s\"\\n' \"${last##*/}\" \"$PREPARE_TEST_OUT\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "curl"), []byte(curl), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	homebrew := BuiltinCatalog()["homebrew"]
+	for name, c := range map[string]Command{"install.sh": homebrew.Install, "uninstall.sh": *homebrew.Uninstall} {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		t.Setenv("PREPARE_TEST_OUT", out)
+		if err := defaultCommandRunner(context.Background(), c, io.Discard); err != nil {
+			t.Fatalf("%s: run error: %v", name, err)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil || strings.TrimSpace(string(got)) != "ran "+name {
+			t.Fatalf("%s: expected the downloaded script to run, got %q (err %v)", name, got, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package dynamic
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventRunStarted   EventType = "run_started"
+	EventStepStarted  EventType = "step_started"
+	EventCheckResult  EventType = "check_result"
+	EventOutput       EventType = "output"
+	EventStepFinished EventType = "step_finished"
+	EventRunFinished  EventType = "run_finished"
+)
+
+// Event is emitted while a plan runs. Which fields are set depends on Type:
+// Tools on run_started, Reason and Version on check_result, Line on output,
+// Step on step_finished and Result and Error on run_finished.
+type Event struct {
+	Type    EventType        `json:"type"`
+	Time    time.Time        `json:"time"`
+	RunID   string           `json:"runId"`
+	ToolID  string           `json:"toolId,omitempty"`
+	Tools   []string         `json:"tools,omitempty"`
+	Reason  string           `json:"reason,omitempty"`
+	Version string           `json:"version,omitempty"`
+	Line    string           `json:"line,omitempty"`
+	Step    *ExecutionStep   `json:"step,omitempty"`
+	Result  *ExecutionResult `json:"result,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// Observer receives run events. Calls are serialized, but they come from the
+// goroutines running steps, so observers must not block for long.
+type Observer interface {
+	OnEvent(Event)
+}
+
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(ev Event) { f(ev) }
+
+// NewNDJSONObserver writes each event to w as one line of JSON.
+func NewNDJSONObserver(w io.Writer) Observer {
+	enc := json.NewEncoder(w)
+	return ObserverFunc(func(ev Event) {
+		_ = enc.Encode(ev)
+	})
+}
+
+// Observe registers o for the events of every later run.
+func (e *Executor) Observe(o Observer) {
+	e.observersMu.Lock()
+	defer e.observersMu.Unlock()
+	e.observers = append(e.observers, o)
+}
+
+func (e *Executor) emit(ev Event) {
+	e.observersMu.Lock()
+	defer e.observersMu.Unlock()
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	for _, o := range e.observers {
+		o.OnEvent(ev)
+	}
+}
+
+// observing reports whether any observer is registered, so output lines are
+// only split into events when someone listens.
+func (e *Executor) observing() bool {
+	e.observersMu.Lock()
+	defer e.observersMu.Unlock()
+	return len(e.observers) > 0
+}
@@ -0,0 +1,71 @@
+package dynamic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExecutorEmitsEvents(t *testing.T) {
+	executor := newTestExecutor(map[string]string{"git": "2.44.0"})
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		fmt.Fprintf(out, "installing %s\npartial", cmd.Name)
+		return nil
+	}
+	events := []Event{}
+	executor.Observe(ObserverFunc(func(ev Event) { events = append(events, ev) }))
+	plan := Plan{Steps: []PlanStep{
+		{Order: 1, Tool: ToolSpec{ID: "git", Install: Command{Name: "git"}, Check: Check{Binary: "git"}}},
+		{Order: 2, Tool: ToolSpec{ID: "go", Install: Command{Name: "go"}, Check: Check{Binary: "go"}}},
+	}}
+
+	result, err := executor.Run(plan, ExecOptions{})
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	got := []string{}
+	for _, ev := range events {
+		if ev.RunID != result.RunID {
+			t.Fatalf("event %s has run id %q, want %q", ev.Type, ev.RunID, result.RunID)
+		}
+		got = append(got, strings.TrimSpace(fmt.Sprintf("%s %s %s%s", ev.Type, ev.ToolID, ev.Reason, ev.Line)))
+	}
+	want := []string{
+		"run_started",
+		"step_started git",
+		"check_result git already_installed",
+		"step_finished git",
+		"step_started go",
+		"check_result go not_installed",
+		"output go installing go",
+		"output go partial",
+		"step_finished go",
+		"run_finished",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected events:\n got %q\nwant %q", got, want)
+	}
+	if last := events[len(events)-1]; last.Result == nil || len(last.Result.Steps) != 2 || last.Error != "" {
+		t.Fatalf("unexpected run_finished event: %#v", last)
+	}
+}
+
+func TestNDJSONObserverWritesOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	observer := NewNDJSONObserver(&buf)
+	observer.OnEvent(Event{Type: EventRunStarted, RunID: "r1", Tools: []string{"git"}})
+	observer.OnEvent(Event{Type: EventOutput, RunID: "r1", ToolID: "git", Line: "done"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	var ev Event
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil || ev.Type != EventOutput || ev.Line != "done" {
+		t.Fatalf("unexpected event %q: %#v (err %v)", lines[1], ev, err)
+	}
+}
@@ -1,10 +1,16 @@
 package dynamic
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -12,97 +18,714 @@ type ExecOptions struct {
 	DryRun    bool
 	Resume    bool
 	StatePath string
+	Jobs      int
+	KeepGoing bool
+	// Retry and Timeout, when set, replace every tool's own policy.
+	Retry   *RetryPolicy
+	Timeout time.Duration
+	// RollbackOnFailure uninstalls the tools this run installed, newest
+	// first, when the run fails.
+	RollbackOnFailure bool
+	// LogDir, when set, receives a <run-id>/<tool>.log file per tool with
+	// the output of its commands. LogOutput, when set, also gets that
+	// output, each line prefixed with the tool id.
+	LogDir    string
+	LogOutput io.Writer
+	// Force resumes from a state file written for a different plan.
+	Force bool
 }
 
-type commandRunner func(cmd Command) error
+const (
+	ReasonAlreadyCompleted = "already_completed"
+	ReasonAlreadyInstalled = "already_installed"
+	ReasonVersionMismatch  = "version_mismatch"
+	ReasonNotInstalled     = "not_installed"
+	ReasonDryRun           = "dry_run"
+	ReasonDependencyFailed = "dependency_failed"
+	ReasonInterrupted      = "interrupted"
+)
+
+const (
+	ActionPreInstall  = "pre_install"
+	ActionPostInstall = "post_install"
+)
+
+// ErrInterrupted is returned when the run's context is cancelled, for
+// example by SIGINT or SIGTERM.
+var ErrInterrupted = errors.New("run interrupted")
+
+// interruptGracePeriod is how long an install may take to exit after being
+// forwarded an interrupt before it is killed.
+const interruptGracePeriod = 10 * time.Second
+
+type commandRunner func(ctx context.Context, cmd Command, out io.Writer) error
 
 type checker func(c Check) bool
 
+type versionProber func(p VersionProbe) (string, error)
+
 type Executor struct {
-	runCommand commandRunner
-	checkTool  checker
+	runCommand   commandRunner
+	checkTool    checker
+	probeVersion versionProber
+	preflight    func() error
+	sleep        func(ctx context.Context, d time.Duration) error
+
+	locksMu sync.Mutex
+	locks   map[string]*sync.Mutex
+
+	observersMu sync.Mutex
+	observers   []Observer
 }
 
 func NewExecutor() *Executor {
 	return &Executor{
-		runCommand: defaultCommandRunner,
-		checkTool:  defaultChecker,
+		runCommand:   defaultCommandRunner,
+		checkTool:    defaultChecker,
+		probeVersion: defaultVersionProber,
+		preflight:    preflight,
+		sleep:        sleepContext,
 	}
 }
 
 func (e *Executor) Run(plan Plan, opts ExecOptions) (ExecutionResult, error) {
-	if err := preflight(); err != nil {
+	return e.RunContext(context.Background(), plan, opts)
+}
+
+// RunContext executes the plan. With opts.Jobs > 1 steps whose dependencies have
+// finished run concurrently; result steps always follow plan order. A failed
+// step stops the run unless opts.KeepGoing is set, in which case only its
+// transitive dependents are skipped. Cancelling ctx interrupts the running
+// installs; the state and the partial result are then saved together.
+func (e *Executor) RunContext(ctx context.Context, plan Plan, opts ExecOptions) (ExecutionResult, error) {
+	if err := e.preflight(); err != nil {
 		return ExecutionResult{}, err
 	}
 	if opts.StatePath == "" {
 		opts.StatePath = ".prepare.state.json"
 	}
+	if opts.Jobs < 1 {
+		opts.Jobs = 1
+	}
+	if opts.Retry != nil {
+		if err := opts.Retry.validate(); err != nil {
+			return ExecutionResult{}, err
+		}
+	}
 
-	state := State{Completed: map[string]bool{}}
-	var err error
+	state := NewState()
+	planHash := PlanCatalogHash(plan)
 	if opts.Resume {
+		unlock, err := LockState(opts.StatePath)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+		defer unlock()
 		state, err = LoadState(opts.StatePath)
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("load state: %w", err)
 		}
+		if state.PlanHash != "" && state.PlanHash != planHash && !opts.Force {
+			return ExecutionResult{}, fmt.Errorf("%w: %s (use --force to resume anyway)", ErrStatePlanMismatch, opts.StatePath)
+		}
+		state.LastRun = nil
+	} else if opts.StatePath != "" && !opts.DryRun {
+		// Without --resume only the tools whose post-install hooks are still
+		// pending carry over from the state file.
+		previous, err := LoadState(opts.StatePath)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("load state: %w", err)
+		}
+		for id, ts := range previous.Tools {
+			if ts.PendingHooks {
+				state.Tools[id] = ts
+			}
+		}
 	}
+	state.PlanHash = planHash
 
+	r := &run{ctx: ctx, executor: e, opts: opts, state: state}
 	result := ExecutionResult{StartedAt: time.Now(), DryRun: opts.DryRun, Steps: []ExecutionStep{}}
-	for _, step := range plan.Steps {
-		stepStart := time.Now()
-		execStep := ExecutionStep{ToolID: step.Tool.ID, Success: true}
-
-		if state.Completed[step.Tool.ID] {
-			execStep.Action = "skip"
-			execStep.Reason = "already_completed"
-			execStep.DurationMs = time.Since(stepStart).Milliseconds()
-			result.Steps = append(result.Steps, execStep)
-			continue
+	result.RunID = newRunID(result.StartedAt)
+	if opts.LogDir != "" && !opts.DryRun {
+		r.logDir = filepath.Join(opts.LogDir, result.RunID)
+		if err := os.MkdirAll(r.logDir, 0o755); err != nil {
+			return ExecutionResult{}, fmt.Errorf("create log directory: %w", err)
 		}
-		if e.checkTool(step.Tool.Check) {
-			execStep.Action = "skip"
-			execStep.Reason = "already_installed"
-			execStep.DurationMs = time.Since(stepStart).Milliseconds()
-			result.Steps = append(result.Steps, execStep)
-			state.Completed[step.Tool.ID] = true
-			if opts.Resume {
-				if err := SaveState(opts.StatePath, state); err != nil {
-					return result, fmt.Errorf("save state: %w", err)
-				}
+		result.LogDir = r.logDir
+	}
+	if opts.LogOutput != nil {
+		r.tee = &syncWriter{w: opts.LogOutput}
+	}
+	planned := make([]string, len(plan.Steps))
+	for i, step := range plan.Steps {
+		planned[i] = step.Tool.ID
+	}
+	r.runID = result.RunID
+	e.emit(Event{Type: EventRunStarted, RunID: r.runID, Tools: planned})
+	steps := make([][]ExecutionStep, len(plan.Steps))
+	index := make(map[string]int, len(plan.Steps))
+	for i, step := range plan.Steps {
+		index[step.Tool.ID] = i
+	}
+
+	type outcome struct {
+		idx int
+		err error
+	}
+	done := make(chan outcome)
+	started := make([]bool, len(plan.Steps))
+	finished := make([]bool, len(plan.Steps))
+	running := 0
+	var runErr error
+	var failures []error
+	for {
+		// Nothing new starts after an interrupt, even with --keep-going.
+		for i := 0; runErr == nil && ctx.Err() == nil && running < opts.Jobs && i < len(plan.Steps); i++ {
+			tool := plan.Steps[i].Tool
+			if started[i] || !dependenciesFinished(tool, index, finished) {
+				continue
 			}
-			continue
+			started[i] = true
+			if failed := failedDependency(tool, index, steps); failed != "" {
+				now := time.Now()
+				steps[i] = []ExecutionStep{{ToolID: tool.ID, Action: "skip", Reason: ReasonDependencyFailed, FailedDependency: failed, StartedAt: now, EndedAt: now}}
+				finished[i] = true
+				r.emitSteps(steps[i])
+				continue
+			}
+			running++
+			e.emit(Event{Type: EventStepStarted, RunID: r.runID, ToolID: tool.ID})
+			go func(i int) {
+				toolSteps, err := r.execute(plan.Steps[i].Tool)
+				steps[i] = toolSteps
+				done <- outcome{idx: i, err: err}
+			}(i)
+		}
+		if ctx.Err() != nil && runErr == nil {
+			runErr = ErrInterrupted
+		}
+		if running == 0 {
+			break
+		}
+		out := <-done
+		running--
+		finished[out.idx] = true
+		r.emitSteps(steps[out.idx])
+		if out.err != nil {
+			if err := r.recordError(plan.Steps[out.idx].Tool.ID, out.err); err != nil {
+				out.err = errors.Join(out.err, err)
+			}
+		}
+		switch {
+		case out.err == nil:
+		case opts.KeepGoing && failedStep(steps[out.idx]) != nil:
+			failures = append(failures, out.err)
+		case runErr == nil:
+			runErr = out.err
+		}
+	}
+
+	for _, toolSteps := range steps {
+		result.Steps = append(result.Steps, toolSteps...)
+	}
+	result.EndedAt = time.Now()
+	if runErr == nil && len(failures) > 0 {
+		runErr = errors.Join(failures...)
+	}
+	if runErr != nil && opts.RollbackOnFailure && !errors.Is(runErr, ErrInterrupted) {
+		if err := r.rollback(&result); err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+		result.EndedAt = time.Now()
+	}
+	if errors.Is(runErr, ErrInterrupted) {
+		r.state.LastRun = &result
+		if err := r.saveInterrupted(); err != nil {
+			runErr = errors.Join(runErr, fmt.Errorf("save state: %w", err))
+		}
+	}
+	ev := Event{Type: EventRunFinished, RunID: r.runID, Result: &result}
+	if runErr != nil {
+		ev.Error = runErr.Error()
+	}
+	e.emit(ev)
+	return result, runErr
+}
+
+// emitSteps reports the finished steps of one tool in order.
+func (r *run) emitSteps(steps []ExecutionStep) {
+	for i := range steps {
+		step := steps[i]
+		r.executor.emit(Event{Type: EventStepFinished, RunID: r.runID, ToolID: step.ToolID, Step: &step})
+	}
+}
+
+// outputLines returns the line handler for a tool's command output: the
+// tee, when set, and output events, when anyone observes the run.
+func (r *run) outputLines(toolID string) func(line string) {
+	var tee func(string)
+	if r.tee != nil {
+		tee = prefixLines(r.tee, toolID)
+	}
+	observing := r.executor.observing()
+	if tee == nil && !observing {
+		return nil
+	}
+	return func(line string) {
+		if tee != nil {
+			tee(line)
+		}
+		if observing {
+			r.executor.emit(Event{Type: EventOutput, RunID: r.runID, ToolID: toolID, Line: line})
+		}
+	}
+}
+
+// dependenciesFinished reports whether every planned dependency of tool has
+// finished. Dependencies outside the plan do not gate the tool.
+func dependenciesFinished(tool ToolSpec, index map[string]int, finished []bool) bool {
+	for _, dep := range tool.Dependencies {
+		if i, ok := index[dep]; ok && !finished[i] {
+			return false
 		}
-		if opts.DryRun {
-			execStep.Action = "install"
-			execStep.Reason = "dry_run"
-			execStep.DurationMs = time.Since(stepStart).Milliseconds()
-			result.Steps = append(result.Steps, execStep)
+	}
+	return true
+}
+
+// failedDependency returns the failed step that one of tool's dependencies
+// failed or was skipped because of, or "" when all of them succeeded.
+func failedDependency(tool ToolSpec, index map[string]int, steps [][]ExecutionStep) string {
+	for _, dep := range tool.Dependencies {
+		i, ok := index[dep]
+		if !ok {
 			continue
 		}
+		if failed := failedStep(steps[i]); failed != nil {
+			if failed.FailedDependency != "" {
+				return failed.FailedDependency
+			}
+			return dep
+		}
+	}
+	return ""
+}
 
+// failedStep returns the first unsuccessful step recorded for a tool.
+func failedStep(steps []ExecutionStep) *ExecutionStep {
+	for i := range steps {
+		if !steps[i].Success {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+type run struct {
+	ctx      context.Context
+	executor *Executor
+	opts     ExecOptions
+
+	runID  string
+	logDir string
+	tee    io.Writer
+
+	mu        sync.Mutex
+	state     State
+	installed []ToolSpec
+}
+
+// execute checks and installs one tool. Besides the install step it records
+// one step per hook phase that runs, so a failing hook is reported apart from
+// the install itself.
+func (r *run) execute(tool ToolSpec) ([]ExecutionStep, error) {
+	e := r.executor
+	execStep := ExecutionStep{ToolID: tool.ID, Success: true, StartedAt: time.Now()}
+
+	if r.completed(tool.ID) {
+		execStep.Action = "skip"
+		execStep.Reason = ReasonAlreadyCompleted
+		return []ExecutionStep{finishStep(execStep)}, nil
+	}
+	status := e.inspect(tool)
+	e.emit(Event{Type: EventCheckResult, RunID: r.runID, ToolID: tool.ID, Reason: status.Reason, Version: status.Version})
+	execStep.Version = status.Version
+	if status.Reason == ReasonAlreadyInstalled {
+		execStep.Action = "skip"
+		execStep.Reason = ReasonAlreadyInstalled
+		steps := []ExecutionStep{finishStep(execStep)}
+		if len(tool.PostInstall) > 0 && r.hooksPending(tool.ID) {
+			// An earlier run installed the tool but its post-install hooks failed.
+			if r.opts.DryRun {
+				return append(steps, finishStep(ExecutionStep{ToolID: tool.ID, Action: ActionPostInstall, Reason: ReasonDryRun, Success: true, StartedAt: time.Now()})), nil
+			}
+			hook, err := r.runHooks(tool, ActionPostInstall, tool.PostInstall)
+			steps = append(steps, hook)
+			if err != nil {
+				return steps, fmt.Errorf("post-install %s: %w", tool.ID, err)
+			}
+		}
+		return steps, r.markCompleted(tool.ID, status.Version)
+	}
+	if r.opts.DryRun {
+		steps := []ExecutionStep{}
+		if len(tool.PreInstall) > 0 {
+			steps = append(steps, finishStep(ExecutionStep{ToolID: tool.ID, Action: ActionPreInstall, Reason: ReasonDryRun, Success: true, StartedAt: time.Now()}))
+		}
 		execStep.Action = "install"
-		if err := e.runCommand(step.Tool.Install); err != nil {
-			execStep.Success = false
-			execStep.Error = err.Error()
-			execStep.DurationMs = time.Since(stepStart).Milliseconds()
-			result.Steps = append(result.Steps, execStep)
-			result.EndedAt = time.Now()
-			return result, fmt.Errorf("install %s: %w", step.Tool.ID, err)
-		}
-
-		state.Completed[step.Tool.ID] = true
-		if opts.Resume {
-			if err := SaveState(opts.StatePath, state); err != nil {
-				return result, fmt.Errorf("save state: %w", err)
+		execStep.Reason = ReasonDryRun
+		steps = append(steps, finishStep(execStep))
+		if len(tool.PostInstall) > 0 {
+			steps = append(steps, finishStep(ExecutionStep{ToolID: tool.ID, Action: ActionPostInstall, Reason: ReasonDryRun, Success: true, StartedAt: time.Now()}))
+		}
+		return steps, nil
+	}
+
+	steps := []ExecutionStep{}
+	if len(tool.PreInstall) > 0 {
+		hook, err := r.runHooks(tool, ActionPreInstall, tool.PreInstall)
+		steps = append(steps, hook)
+		if err != nil {
+			return steps, fmt.Errorf("pre-install %s: %w", tool.ID, err)
+		}
+		execStep.StartedAt = time.Now()
+	}
+
+	execStep.Action = "install"
+	execStep.Reason = status.Reason
+	err := r.install(tool, &execStep)
+	if err == nil {
+		err = e.verifyVersion(tool, &execStep)
+	}
+	if err != nil {
+		execStep.Success = false
+		execStep.Error = err.Error()
+		if errors.Is(err, ErrInterrupted) {
+			execStep.Reason = ReasonInterrupted
+		}
+		return append(steps, finishStep(execStep)), fmt.Errorf("install %s: %w", tool.ID, err)
+	}
+	r.recordInstalled(tool)
+	steps = append(steps, finishStep(execStep))
+
+	if len(tool.PostInstall) > 0 {
+		hook, err := r.runHooks(tool, ActionPostInstall, tool.PostInstall)
+		steps = append(steps, hook)
+		if err != nil {
+			err = fmt.Errorf("post-install %s: %w", tool.ID, err)
+			if stateErr := r.updateTool(tool.ID, ToolState{Version: execStep.Version, PendingHooks: true}); stateErr != nil {
+				err = errors.Join(err, stateErr)
 			}
+			return steps, err
 		}
+	}
+	return steps, r.markCompleted(tool.ID, execStep.Version)
+}
 
-		execStep.DurationMs = time.Since(stepStart).Milliseconds()
-		result.Steps = append(result.Steps, execStep)
+// runHooks runs a hook phase's commands in order, stopping at the first
+// failure. Hooks use the tool's timeout but are not retried.
+func (r *run) runHooks(tool ToolSpec, action string, hooks []Command) (ExecutionStep, error) {
+	step := ExecutionStep{ToolID: tool.ID, Action: action, Success: true, StartedAt: time.Now()}
+	timeout := r.opts.Timeout
+	if timeout == 0 {
+		timeout, _ = parseTimeout(tool.Timeout)
+	}
+	err := captureOutput(r.logDir, tool.ID, &step, r.outputLines(tool.ID), func(out io.Writer) error {
+		for _, hook := range hooks {
+			err := r.executor.withLock(lockFor(tool, hook), func() error { return r.runWithTimeout(tool, hook, timeout, out) })
+			if err != nil {
+				return fmt.Errorf("%s: %w", hook.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		step.Success = false
+		step.Error = err.Error()
+		step.TimedOut = errors.Is(err, context.DeadlineExceeded)
+		if errors.Is(err, ErrInterrupted) {
+			step.Reason = ReasonInterrupted
+		}
 	}
+	return finishStep(step), err
+}
 
-	result.EndedAt = time.Now()
-	return result, nil
+func finishStep(step ExecutionStep) ExecutionStep {
+	step.EndedAt = time.Now()
+	step.DurationMs = step.EndedAt.Sub(step.StartedAt).Milliseconds()
+	return step
+}
+
+// install runs the install command, retrying failed or timed-out attempts
+// according to the tool's retry policy. Each attempt gets the full timeout.
+func (r *run) install(tool ToolSpec, execStep *ExecutionStep) error {
+	e := r.executor
+	policy := RetryPolicy{}
+	if tool.Retry != nil {
+		policy = *tool.Retry
+	}
+	if r.opts.Retry != nil {
+		policy = *r.opts.Retry
+	}
+	timeout := r.opts.Timeout
+	if timeout == 0 {
+		var err error
+		if timeout, err = parseTimeout(tool.Timeout); err != nil {
+			return err
+		}
+	}
+
+	return captureOutput(r.logDir, tool.ID, execStep, r.outputLines(tool.ID), func(out io.Writer) error {
+		for attempt := 1; ; attempt++ {
+			execStep.Attempts = attempt
+			err := e.withLock(lockFor(tool, tool.Install), func() error { return r.runWithTimeout(tool, tool.Install, timeout, out) })
+			execStep.TimedOut = errors.Is(err, context.DeadlineExceeded)
+			if err == nil || errors.Is(err, ErrInterrupted) || attempt > policy.Retries {
+				return err
+			}
+			if err := e.sleep(r.ctx, policy.delayBefore(attempt)); err != nil {
+				return ErrInterrupted
+			}
+		}
+	})
+}
+
+func (r *run) runWithTimeout(tool ToolSpec, c Command, timeout time.Duration, out io.Writer) error {
+	ctx := r.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := r.executor.runToolCommand(ctx, tool, c, out)
+	switch {
+	case err == nil:
+		return nil
+	case r.ctx.Err() != nil:
+		return ErrInterrupted
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("timed out after %s: %w", timeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+func (r *run) recordInstalled(tool ToolSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.installed = append(r.installed, tool)
+}
+
+// rollback uninstalls the tools installed during this run in reverse order
+// of completion. Tools skipped as already installed are left alone. It keeps
+// going past failures and reports every undo in result.RolledBack.
+func (r *run) rollback(result *ExecutionResult) error {
+	e := r.executor
+	var errs []error
+	for i := len(r.installed) - 1; i >= 0; i-- {
+		tool := r.installed[i]
+		step := ExecutionStep{ToolID: tool.ID, Action: "rollback", Success: true, StartedAt: time.Now()}
+		var err error
+		if tool.Uninstall == nil {
+			err = fmt.Errorf("tool %q has no uninstall command", tool.ID)
+		} else {
+			err = captureOutput(r.logDir, tool.ID, &step, r.outputLines(tool.ID), func(out io.Writer) error {
+				return e.withLock(lockFor(tool, *tool.Uninstall), func() error { return e.runToolCommand(r.ctx, tool, *tool.Uninstall, out) })
+			})
+		}
+		if err != nil {
+			step.Success = false
+			step.Error = err.Error()
+			errs = append(errs, fmt.Errorf("rollback %s: %w", tool.ID, err))
+		} else {
+			delete(r.state.Tools, tool.ID)
+		}
+		step = finishStep(step)
+		result.RolledBack = append(result.RolledBack, step)
+		r.emitSteps([]ExecutionStep{step})
+	}
+	if r.opts.Resume {
+		if err := SaveState(r.opts.StatePath, r.state); err != nil {
+			errs = append(errs, fmt.Errorf("save state: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *run) completed(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Tools[id].Completed
+}
+
+func (r *run) markCompleted(id string, version string) error {
+	now := time.Now()
+	return r.updateTool(id, ToolState{Completed: true, CompletedAt: &now, Version: version})
+}
+
+// recordError keeps the tool's last error in the state; a tool that was
+// completed by an earlier run stays completed.
+func (r *run) recordError(id string, err error) error {
+	r.mu.Lock()
+	ts := r.state.Tools[id]
+	r.mu.Unlock()
+	ts.LastError = err.Error()
+	return r.updateTool(id, ts)
+}
+
+func (r *run) hooksPending(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.Tools[id].PendingHooks
+}
+
+// updateTool records ts in the state. Resumable runs save the whole state;
+// other runs only write tools whose post-install hooks are or were pending
+// into the state file, so the next run knows to rerun the hooks.
+func (r *run) updateTool(id string, ts ToolState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wasPending := r.state.Tools[id].PendingHooks
+	r.state.Tools[id] = ts
+	if r.opts.Resume {
+		if err := SaveState(r.opts.StatePath, r.state); err != nil {
+			return fmt.Errorf("save state: %w", err)
+		}
+		return nil
+	}
+	if r.opts.StatePath == "" || r.opts.DryRun || (!ts.PendingHooks && !wasPending) {
+		return nil
+	}
+	err := updateStateFile(r.opts.StatePath, func(state *State) { state.Tools[id] = ts })
+	if err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	return nil
+}
+
+// saveInterrupted writes the state of an interrupted run. Resumable runs
+// already hold the state lock and loaded the state file; other runs take
+// the lock just for this write and merge into the state file rather than
+// replacing the tools recorded by earlier runs.
+func (r *run) saveInterrupted() error {
+	if r.opts.Resume {
+		return SaveState(r.opts.StatePath, r.state)
+	}
+	return updateStateFile(r.opts.StatePath, func(state *State) {
+		for id, ts := range r.state.Tools {
+			state.Tools[id] = ts
+		}
+		state.PlanHash = r.state.PlanHash
+		state.LastRun = r.state.LastRun
+	})
+}
+
+// runToolCommand expands c with the tool's variables and runs it.
+func (e *Executor) runToolCommand(ctx context.Context, tool ToolSpec, c Command, out io.Writer) error {
+	expanded, err := expandCommand(c, toolVariables(tool))
+	if err != nil {
+		return err
+	}
+	return e.runCommand(ctx, expanded, out)
+}
+
+// withLock runs fn holding the package-manager lock key, or unlocked when
+// key is empty, so parallel runs never invoke two brew commands at once.
+func (e *Executor) withLock(key string, fn func() error) error {
+	if key == "" {
+		return fn()
+	}
+	e.locksMu.Lock()
+	if e.locks == nil {
+		e.locks = map[string]*sync.Mutex{}
+	}
+	mu, ok := e.locks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		e.locks[key] = mu
+	}
+	e.locksMu.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return fn()
+}
+
+// lockFor returns the package-manager lock c takes when run for tool: the
+// tool's declared Lock, or "brew" for commands that run brew.
+func lockFor(tool ToolSpec, c Command) string {
+	if tool.Lock != "" {
+		return tool.Lock
+	}
+	if filepath.Base(c.Name) == "brew" {
+		return "brew"
+	}
+	return ""
+}
+
+// ProbeVersions returns the installed version of every planned tool that is
+// installed and has a version probe.
+func (e *Executor) ProbeVersions(plan Plan) map[string]string {
+	versions := map[string]string{}
+	for _, step := range plan.Steps {
+		if step.Tool.Check.Version == nil || !e.checkTool(step.Tool.Check) {
+			continue
+		}
+		if v, err := e.probeVersion(*step.Tool.Check.Version); err == nil {
+			versions[step.Tool.ID] = v
+		}
+	}
+	return versions
+}
+
+type toolStatus struct {
+	Reason  string
+	Version string
+}
+
+// inspect tells apart a tool that is missing, installed at a version that
+// does not satisfy its constraint, and installed and satisfying.
+func (e *Executor) inspect(tool ToolSpec) toolStatus {
+	if !e.checkTool(tool.Check) {
+		return toolStatus{Reason: ReasonNotInstalled}
+	}
+	if tool.Check.Version == nil {
+		return toolStatus{Reason: ReasonAlreadyInstalled}
+	}
+	version, err := e.probeVersion(*tool.Check.Version)
+	if err != nil {
+		if tool.Constraint != "" {
+			return toolStatus{Reason: ReasonVersionMismatch}
+		}
+		return toolStatus{Reason: ReasonAlreadyInstalled}
+	}
+	if ok, err := SatisfiesConstraint(version, tool.Constraint); err != nil || !ok {
+		return toolStatus{Reason: ReasonVersionMismatch, Version: version}
+	}
+	return toolStatus{Reason: ReasonAlreadyInstalled, Version: version}
+}
+
+// verifyVersion probes the version after an install and fails the step when
+// it still does not satisfy the tool's constraint.
+func (e *Executor) verifyVersion(tool ToolSpec, execStep *ExecutionStep) error {
+	if tool.Check.Version == nil {
+		return nil
+	}
+	version, err := e.probeVersion(*tool.Check.Version)
+	if err != nil {
+		if tool.Constraint != "" {
+			return fmt.Errorf("probe installed version: %w", err)
+		}
+		return nil
+	}
+	execStep.Version = version
+	ok, err := SatisfiesConstraint(version, tool.Constraint)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("installed version %s does not satisfy %q", version, tool.Constraint)
+	}
+	return nil
 }
 
 func preflight() error {
@@ -112,29 +735,37 @@ func preflight() error {
 	return nil
 }
 
-func defaultCommandRunner(c Command) error {
-	var cmd *exec.Cmd
-	if c.Shell != "" {
-		cmd = exec.Command(c.Name, c.Args...)
-	} else {
-		cmd = exec.Command(c.Name, c.Args...)
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func defaultCommandRunner(ctx context.Context, c Command, out io.Writer) error {
+	cmd := newExecCmd(ctx, c)
+	// Ask the install to stop like a terminal Ctrl-C would, and kill it only
+	// if it is still running after the grace period.
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = interruptGracePeriod
+	cmd.Stdout = out
+	cmd.Stderr = out
 	return cmd.Run()
 }
 
-func defaultChecker(c Check) bool {
-	if c.Binary != "" {
-		_, err := exec.LookPath(c.Binary)
-		if err == nil {
-			return true
-		}
+func defaultVersionProber(p VersionProbe) (string, error) {
+	pattern, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return "", err
 	}
-	if c.PathExists != "" {
-		if _, err := os.Stat(c.PathExists); err == nil {
-			return true
-		}
+	c, err := expandCommand(p.Command, nil)
+	if err != nil {
+		return "", err
+	}
+	out, err := runProbe(c, (*exec.Cmd).CombinedOutput)
+	if err != nil {
+		return "", err
+	}
+	match := pattern.FindSubmatch(out)
+	switch {
+	case match == nil:
+		return "", fmt.Errorf("version pattern %q did not match output of %s", p.Pattern, p.Command.Name)
+	case len(match) > 1:
+		return string(match[1]), nil
+	default:
+		return string(match[0]), nil
 	}
-	return false
 }
@@ -1,8 +1,17 @@
 package dynamic
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestExecutorDryRunDoesNotExecuteCommand(t *testing.T) {
@@ -12,7 +21,7 @@ func TestExecutorDryRunDoesNotExecuteCommand(t *testing.T) {
 	executor := NewExecutor()
 	called := false
 	executor.checkTool = func(c Check) bool { return false }
-	executor.runCommand = func(cmd Command) error {
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
 		called = true
 		return nil
 	}
@@ -29,3 +38,529 @@ func TestExecutorDryRunDoesNotExecuteCommand(t *testing.T) {
 		t.Fatalf("unexpected dry-run result: %#v", result.Steps)
 	}
 }
+
+func newTestExecutor(installed map[string]string) *Executor {
+	executor := NewExecutor()
+	executor.preflight = func() error { return nil }
+	executor.checkTool = func(c Check) bool {
+		_, ok := installed[c.Binary]
+		return ok
+	}
+	executor.probeVersion = func(p VersionProbe) (string, error) {
+		return installed[p.Command.Name], nil
+	}
+	return executor
+}
+
+func TestExecutorDistinguishesVersionStatus(t *testing.T) {
+	probe := func(name string) Check {
+		return Check{Binary: name, Version: &VersionProbe{Command: Command{Name: name}, Pattern: `(.*)`}}
+	}
+	executor := newTestExecutor(map[string]string{"go": "1.23.4", "node": "18.19.0"})
+	plan := Plan{Steps: []PlanStep{
+		{Order: 1, Tool: ToolSpec{ID: "go", Check: probe("go"), Constraint: ">=1.22 <1.24"}},
+		{Order: 2, Tool: ToolSpec{ID: "nodejs", Check: probe("node"), Constraint: "^20"}},
+		{Order: 3, Tool: ToolSpec{ID: "docker", Check: probe("docker")}},
+	}}
+
+	result, err := executor.Run(plan, ExecOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if result.Steps[0].Reason != ReasonAlreadyInstalled || result.Steps[0].Version != "1.23.4" {
+		t.Fatalf("expected satisfying go to be skipped, got %#v", result.Steps[0])
+	}
+	if result.Steps[1].Action != "install" || result.Steps[1].Version != "18.19.0" {
+		t.Fatalf("expected mismatched node to be installed, got %#v", result.Steps[1])
+	}
+
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error { return nil }
+	result, err = executor.Run(plan, ExecOptions{})
+	if err == nil {
+		t.Fatal("expected node to fail: its version still does not satisfy the constraint")
+	}
+	if result.Steps[1].Reason != ReasonVersionMismatch || result.Steps[1].Success {
+		t.Fatalf("expected version_mismatch failure, got %#v", result.Steps[1])
+	}
+
+	plan.Steps = plan.Steps[2:]
+	result, err = executor.Run(plan, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if result.Steps[0].Reason != ReasonNotInstalled {
+		t.Fatalf("expected not_installed reason, got %#v", result.Steps[0])
+	}
+}
+
+func TestExecutorRunsIndependentStepsConcurrently(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	var mu sync.Mutex
+	order := []string{}
+	active, maxBrew := map[string]int{}, 0
+	bothStarted := make(chan struct{})
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	go func() {
+		arrived.Wait()
+		close(bothStarted)
+	}()
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		mu.Lock()
+		order = append(order, cmd.Args[0])
+		active[cmd.Name]++
+		if cmd.Name == "brew" && active["brew"] > maxBrew {
+			maxBrew = active["brew"]
+		}
+		mu.Unlock()
+		if cmd.Name == "curl" {
+			arrived.Done()
+			select {
+			case <-bothStarted:
+			case <-time.After(2 * time.Second):
+				t.Error("expected independent curl installs to overlap")
+			}
+		} else {
+			time.Sleep(5 * time.Millisecond)
+		}
+		mu.Lock()
+		active[cmd.Name]--
+		mu.Unlock()
+		return nil
+	}
+	tool := func(id string, installer string, deps ...string) PlanStep {
+		return PlanStep{Tool: ToolSpec{ID: id, Dependencies: deps, Install: Command{Name: installer, Args: []string{id}}, Check: Check{Binary: id}}}
+	}
+	plan := Plan{Steps: []PlanStep{
+		tool("homebrew", "bash"),
+		tool("git", "brew", "homebrew"),
+		tool("go", "brew", "homebrew"),
+		tool("rustup", "curl"),
+		tool("nvm", "curl"),
+		tool("vscode", "brew", "homebrew", "git"),
+	}}
+
+	result, err := executor.Run(plan, ExecOptions{Jobs: 4})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if maxBrew != 1 {
+		t.Fatalf("expected brew installs to be serialized, saw %d at once", maxBrew)
+	}
+	position := map[string]int{}
+	for i, id := range order {
+		position[id] = i
+	}
+	if position["git"] < position["homebrew"] || position["vscode"] < position["git"] {
+		t.Fatalf("dependencies did not gate dependents: %v", order)
+	}
+	for i, step := range result.Steps {
+		if step.ToolID != plan.Steps[i].Tool.ID {
+			t.Fatalf("expected result in plan order, got %q at %d", step.ToolID, i)
+		}
+		if step.StartedAt.IsZero() || step.EndedAt.Before(step.StartedAt) {
+			t.Fatalf("expected real start and end times, got %#v", step)
+		}
+	}
+}
+
+func TestExecutorKeepGoingSkipsOnlyDependents(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		if cmd.Name == "dotnet" {
+			return errors.New("cask download failed")
+		}
+		return nil
+	}
+	tool := func(id string, deps ...string) PlanStep {
+		return PlanStep{Tool: ToolSpec{ID: id, Dependencies: deps, Install: Command{Name: id}, Check: Check{Binary: id}}}
+	}
+	plan := Plan{Steps: []PlanStep{
+		tool("homebrew"),
+		tool("dotnet", "homebrew"),
+		tool("git", "homebrew"),
+		tool("omnisharp", "dotnet"),
+		tool("csharp-ls", "omnisharp"),
+	}}
+
+	if _, err := executor.Run(plan, ExecOptions{}); err == nil {
+		t.Fatal("expected default run to stop at the failure")
+	}
+
+	result, err := executor.Run(plan, ExecOptions{KeepGoing: true, Jobs: 2})
+	if err == nil || !strings.Contains(err.Error(), "install dotnet") {
+		t.Fatalf("expected keep-going run to report the dotnet failure, got %v", err)
+	}
+	got := map[string]ExecutionStep{}
+	for _, step := range result.Steps {
+		got[step.ToolID] = step
+	}
+	if len(got) != len(plan.Steps) {
+		t.Fatalf("expected every step in the result, got %#v", result.Steps)
+	}
+	if !got["git"].Success {
+		t.Fatalf("expected unrelated git to install, got %#v", got["git"])
+	}
+	for _, id := range []string{"omnisharp", "csharp-ls"} {
+		if got[id].Reason != ReasonDependencyFailed || got[id].FailedDependency != "dotnet" {
+			t.Fatalf("expected %s skipped because of dotnet, got %#v", id, got[id])
+		}
+	}
+}
+
+func TestExecutorRetriesWithBackoff(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	delays := []time.Duration{}
+	executor.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	calls := 0
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		calls++
+		if calls < 3 {
+			return errors.New("curl: (56) connection reset")
+		}
+		return nil
+	}
+	tool := ToolSpec{ID: "rustup", Install: Command{Name: "curl"}, Check: Check{Binary: "rustc"}, Retry: &RetryPolicy{Retries: 3, Delay: "100ms"}}
+
+	result, err := executor.Run(Plan{Steps: []PlanStep{{Order: 1, Tool: tool}}}, ExecOptions{})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if result.Steps[0].Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %#v", result.Steps[0])
+	}
+	if len(delays) != 2 || delays[0] != 100*time.Millisecond || delays[1] != 200*time.Millisecond {
+		t.Fatalf("expected exponential backoff, got %v", delays)
+	}
+
+	calls, delays = 0, nil
+	result, err = executor.Run(Plan{Steps: []PlanStep{{Order: 1, Tool: tool}}}, ExecOptions{Retry: &RetryPolicy{Retries: 1, Backoff: BackoffFixed}})
+	if err == nil || result.Steps[0].Attempts != 2 {
+		t.Fatalf("expected CLI override to allow only 2 attempts, got %#v (err %v)", result.Steps, err)
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	policy := RetryPolicy{Delay: "1s"}
+	if d := policy.delayBefore(4); d != 8*time.Second {
+		t.Fatalf("expected 8s before the fourth retry, got %v", d)
+	}
+	for _, n := range []int{10, 64, 100, 1 << 20} {
+		if d := policy.delayBefore(n); d != maxRetryDelay {
+			t.Fatalf("expected retry %d to wait %v, got %v", n, maxRetryDelay, d)
+		}
+	}
+	long := RetryPolicy{Delay: "10m", Backoff: BackoffExponential}
+	if d := long.delayBefore(100); d != 10*time.Minute {
+		t.Fatalf("expected a delay above the cap to be kept, got %v", d)
+	}
+}
+
+func TestExecutorTimesOutHungInstall(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	tool := ToolSpec{ID: "docker", Install: Command{Name: "brew"}, Check: Check{Binary: "docker"}, Timeout: "10ms"}
+
+	result, err := executor.Run(Plan{Steps: []PlanStep{{Order: 1, Tool: tool}}}, ExecOptions{})
+	if err == nil {
+		t.Fatal("expected hung install to fail")
+	}
+	step := result.Steps[0]
+	if !step.TimedOut || step.Attempts != 1 || !strings.Contains(step.Error, "timed out after 10ms") {
+		t.Fatalf("expected timed-out step, got %#v", step)
+	}
+}
+
+func TestExecutorInterruptStopsKeepGoingRun(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := []string{}
+	executor.runCommand = func(cmdCtx context.Context, cmd Command, out io.Writer) error {
+		calls = append(calls, cmd.Name)
+		cancel()
+		<-cmdCtx.Done()
+		return errors.New("signal: interrupt")
+	}
+	tool := func(id string) PlanStep {
+		return PlanStep{Tool: ToolSpec{ID: id, Install: Command{Name: id}, Check: Check{Binary: id}}}
+	}
+	plan := Plan{Steps: []PlanStep{tool("a"), tool("b")}}
+
+	_, err := executor.RunContext(ctx, plan, ExecOptions{KeepGoing: true, StatePath: filepath.Join(t.TempDir(), "state.json")})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if strings.Join(calls, ",") != "a" {
+		t.Fatalf("expected no install to start after the interrupt, got %v", calls)
+	}
+}
+
+func TestExecutorInterruptSavesStateAndPartialResult(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executor.runCommand = func(cmdCtx context.Context, cmd Command, out io.Writer) error {
+		if cmd.Name != "docker" {
+			return nil
+		}
+		cancel()
+		<-cmdCtx.Done()
+		return errors.New("signal: interrupt")
+	}
+	tool := func(id string) PlanStep {
+		return PlanStep{Tool: ToolSpec{ID: id, Install: Command{Name: id}, Check: Check{Binary: id}}}
+	}
+	plan := Plan{Steps: []PlanStep{tool("git"), tool("docker"), tool("go")}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	earlier := NewState()
+	earlier.Tools["nodejs"] = ToolState{Completed: true, Version: "20.11.1"}
+	if err := SaveState(statePath, earlier); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := executor.RunContext(ctx, plan, ExecOptions{StatePath: statePath})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	if len(result.Steps) != 2 || result.Steps[1].Reason != ReasonInterrupted || result.Steps[1].Success {
+		t.Fatalf("expected docker interrupted and go never started, got %#v", result.Steps)
+	}
+
+	state, err := LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState error: %v", err)
+	}
+	if !state.Tools["git"].Completed || state.Tools["docker"].Completed {
+		t.Fatalf("expected only git completed, got %#v", state.Tools)
+	}
+	if state.Tools["nodejs"].Version != "20.11.1" {
+		t.Fatalf("expected tools from earlier runs to be kept, got %#v", state.Tools)
+	}
+	if state.Version != StateVersion || state.PlanHash != PlanCatalogHash(plan) || state.Tools["git"].CompletedAt == nil {
+		t.Fatalf("expected v2 state with plan hash and completion time, got %#v", state)
+	}
+	if !strings.Contains(state.Tools["docker"].LastError, "interrupted") {
+		t.Fatalf("expected docker's last error to be recorded, got %#v", state.Tools["docker"])
+	}
+	if state.LastRun == nil || len(state.LastRun.Steps) != 2 {
+		t.Fatalf("expected partial result in state, got %#v", state.LastRun)
+	}
+
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error { return nil }
+	result, err = executor.Run(plan, ExecOptions{Resume: true, StatePath: statePath})
+	if err != nil {
+		t.Fatalf("resume error: %v", err)
+	}
+	if result.Steps[0].Reason != ReasonAlreadyCompleted || result.Steps[1].Reason != ReasonNotInstalled {
+		t.Fatalf("expected resume to skip git and retry docker, got %#v", result.Steps)
+	}
+}
+
+func TestExecutorRollbackOnFailureUndoesThisRunsInstalls(t *testing.T) {
+	executor := newTestExecutor(map[string]string{"brew": "4.2.0"})
+	commands := []string{}
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		commands = append(commands, strings.Join(append([]string{cmd.Name}, cmd.Args...), " "))
+		if cmd.Name == "fail" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	tool := func(id string, install string, deps ...string) PlanStep {
+		return PlanStep{Tool: ToolSpec{ID: id, Dependencies: deps, Install: Command{Name: install, Args: []string{id}}, Uninstall: brewCommand("uninstall", id), Check: Check{Binary: id}}}
+	}
+	plan := Plan{Steps: []PlanStep{
+		tool("brew", "install"),
+		tool("git", "install", "brew"),
+		tool("go", "install", "brew"),
+		tool("docker", "fail", "brew"),
+	}}
+
+	result, err := executor.Run(plan, ExecOptions{RollbackOnFailure: true})
+	if err == nil {
+		t.Fatal("expected run to fail")
+	}
+	want := []string{"install git", "install go", "fail docker", "brew uninstall go", "brew uninstall git"}
+	if strings.Join(commands, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected commands:\n got %q\nwant %q", commands, want)
+	}
+	if len(result.RolledBack) != 2 || result.RolledBack[0].ToolID != "go" || result.RolledBack[0].Action != "rollback" || !result.RolledBack[1].Success {
+		t.Fatalf("unexpected rollback report: %#v", result.RolledBack)
+	}
+}
+
+func TestExecutorRunsHooksAsSeparateSteps(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	commands := []string{}
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		commands = append(commands, cmd.Name)
+		if cmd.Name == "git-config" {
+			return errors.New("exit status 128")
+		}
+		return nil
+	}
+	tool := ToolSpec{
+		ID:          "vscode",
+		Install:     Command{Name: "brew"},
+		Check:       Check{Binary: "code"},
+		PreInstall:  []Command{{Name: "softwareupdate"}},
+		PostInstall: []Command{{Name: "code-ext"}, {Name: "git-config"}, {Name: "never"}},
+	}
+	plan := Plan{Steps: []PlanStep{{Order: 1, Tool: tool}}}
+
+	result, err := executor.Run(plan, ExecOptions{DryRun: true})
+	if err != nil || len(result.Steps) != 3 || len(commands) != 0 {
+		t.Fatalf("expected three dry-run steps and no commands, got %#v (err %v)", result.Steps, err)
+	}
+
+	result, err = executor.Run(plan, ExecOptions{StatePath: filepath.Join(t.TempDir(), "state.json")})
+	if err == nil || !strings.Contains(err.Error(), "post-install vscode") {
+		t.Fatalf("expected post-install failure, got %v", err)
+	}
+	if strings.Join(commands, ",") != "softwareupdate,brew,code-ext,git-config" {
+		t.Fatalf("unexpected commands: %v", commands)
+	}
+	actions := []string{}
+	for _, step := range result.Steps {
+		actions = append(actions, step.Action)
+	}
+	if strings.Join(actions, ",") != "pre_install,install,post_install" {
+		t.Fatalf("unexpected steps: %v", actions)
+	}
+	if !result.Steps[1].Success || result.Steps[2].Success || !strings.Contains(result.Steps[2].Error, "git-config") {
+		t.Fatalf("expected install to succeed and the post-install hook to fail, got %#v", result.Steps)
+	}
+}
+
+func TestExecutorRerunsPendingPostInstallHooks(t *testing.T) {
+	installed := map[string]string{}
+	executor := newTestExecutor(installed)
+	hookErr := errors.New("exit status 128")
+	commands := []string{}
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		commands = append(commands, cmd.Name)
+		if cmd.Name == "brew" {
+			installed["code"] = "1.90.0"
+			return nil
+		}
+		return hookErr
+	}
+	tool := ToolSpec{ID: "vscode", Install: Command{Name: "brew"}, Check: Check{Binary: "code"}, PostInstall: []Command{{Name: "git"}}}
+	plan := Plan{Steps: []PlanStep{{Order: 1, Tool: tool}}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	if _, err := executor.Run(plan, ExecOptions{StatePath: statePath}); err == nil {
+		t.Fatal("expected the post-install hook to fail")
+	}
+	state, err := LoadState(statePath)
+	if err != nil || !state.Tools["vscode"].PendingHooks || state.Tools["vscode"].Completed {
+		t.Fatalf("expected vscode hooks to be pending, got %#v (err %v)", state.Tools, err)
+	}
+
+	hookErr = nil
+	result, err := executor.Run(plan, ExecOptions{StatePath: statePath})
+	if err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if strings.Join(commands, ",") != "brew,git,git" {
+		t.Fatalf("expected only the hook to rerun, got %v", commands)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].Reason != ReasonAlreadyInstalled || result.Steps[1].Action != ActionPostInstall || !result.Steps[1].Success {
+		t.Fatalf("expected skipped install and a successful post-install step, got %#v", result.Steps)
+	}
+	if state, err = LoadState(statePath); err != nil || state.Tools["vscode"].PendingHooks {
+		t.Fatalf("expected pending hooks to be cleared, got %#v (err %v)", state.Tools, err)
+	}
+
+	if _, err := executor.Run(plan, ExecOptions{StatePath: statePath}); err != nil || len(commands) != 3 {
+		t.Fatalf("expected nothing to rerun once the hooks succeeded, got %v (err %v)", commands, err)
+	}
+	if vscode := BuiltinCatalog()["vscode"]; !strings.Contains(strings.Join(vscode.Dependencies, ","), "git") {
+		t.Fatal("expected vscode to depend on git, which its post-install hook runs")
+	}
+}
+
+func TestExecutorSerializesBrewHooks(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	var mu sync.Mutex
+	active, maxBrew := 0, 0
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		if cmd.Name != "brew" {
+			return nil
+		}
+		mu.Lock()
+		active++
+		maxBrew = max(maxBrew, active)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return nil
+	}
+	tool := func(id string) PlanStep {
+		return PlanStep{Tool: ToolSpec{
+			ID:          id,
+			Install:     Command{Name: "curl"},
+			Check:       Check{Binary: id},
+			PostInstall: []Command{{Name: "brew", Args: []string{"link", id}}},
+		}}
+	}
+	plan := Plan{Steps: []PlanStep{tool("rustup"), tool("nvm")}}
+
+	if _, err := executor.Run(plan, ExecOptions{Jobs: 2}); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if maxBrew != 1 {
+		t.Fatalf("expected brew hooks to be serialized, saw %d at once", maxBrew)
+	}
+}
+
+func TestExecutorCapturesStepLogs(t *testing.T) {
+	executor := newTestExecutor(map[string]string{})
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		for i := 1; i <= 25; i++ {
+			fmt.Fprintf(out, "%s line %d\n", cmd.Name, i)
+		}
+		if cmd.Name == "install-go" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	plan := Plan{Steps: []PlanStep{
+		{Order: 1, Tool: ToolSpec{ID: "git", Install: Command{Name: "install-git"}, Check: Check{Binary: "git"}}},
+		{Order: 2, Tool: ToolSpec{ID: "go", Install: Command{Name: "install-go"}, Check: Check{Binary: "go"}}},
+	}}
+	logDir := t.TempDir()
+	var tee strings.Builder
+
+	result, err := executor.Run(plan, ExecOptions{LogDir: logDir, LogOutput: &tee})
+	if err == nil {
+		t.Fatal("expected run to fail")
+	}
+	if result.RunID == "" || result.LogDir != filepath.Join(logDir, result.RunID) {
+		t.Fatalf("unexpected run id %q and log dir %q", result.RunID, result.LogDir)
+	}
+	git, goStep := result.Steps[0], result.Steps[1]
+	if git.LogPath != filepath.Join(result.LogDir, "git.log") || git.OutputTail != "" {
+		t.Fatalf("unexpected git step: %#v", git)
+	}
+	b, err := os.ReadFile(goStep.LogPath)
+	if err != nil || strings.Count(string(b), "\n") != 25 {
+		t.Fatalf("expected full go log, got %q (err %v)", b, err)
+	}
+	tail := strings.Split(goStep.OutputTail, "\n")
+	if len(tail) != outputTailLines || tail[0] != "install-go line 6" || tail[len(tail)-1] != "install-go line 25" {
+		t.Fatalf("unexpected output tail: %q", goStep.OutputTail)
+	}
+	if !strings.Contains(tee.String(), "[go] install-go line 1\n") {
+		t.Fatalf("expected prefixed tee output, got %q", tee.String())
+	}
+}
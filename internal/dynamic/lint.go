@@ -0,0 +1,342 @@
+package dynamic
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Diagnostic struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Message  string   `json:"message"`
+}
+
+type LintRule struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+const (
+	RuleSyntax         = "PRE000"
+	RuleAPIVersion     = "PRE001"
+	RuleUnknownTool    = "PRE002"
+	RuleUnknownProfile = "PRE003"
+	RuleInheritance    = "PRE004"
+	RuleToolDefinition = "PRE005"
+	RuleExcludedDep    = "PRE006"
+
+	RuleRedundantTool   = "PRE101"
+	RuleShadowedProfile = "PRE102"
+	RuleUnusedProfile   = "PRE103"
+	RuleImpliedTool     = "PRE104"
+	RuleUnusedExclude   = "PRE105"
+	RuleUnselectedTool  = "PRE106"
+)
+
+func LintRules() []LintRule {
+	return []LintRule{
+		{Code: RuleSyntax, Name: "manifest-syntax", Severity: SeverityError, Description: "Manifest must be valid YAML or JSON with known keys"},
+		{Code: RuleAPIVersion, Name: "api-version", Severity: SeverityError, Description: "apiVersion must be present and supported"},
+		{Code: RuleUnknownTool, Name: "unknown-tool", Severity: SeverityError, Description: "Referenced tools must exist in the catalog"},
+		{Code: RuleUnknownProfile, Name: "unknown-base-profile", Severity: SeverityError, Description: "Profiles may only extend known profiles"},
+		{Code: RuleInheritance, Name: "inheritance-cycle", Severity: SeverityError, Description: "Profile inheritance must not form a cycle"},
+		{Code: RuleToolDefinition, Name: "invalid-tool-definition", Severity: SeverityError, Description: "Manifest tool definitions must have an install command, a check and known dependencies"},
+		{Code: RuleExcludedDep, Name: "excluded-dependency", Severity: SeverityError, Description: "Profiles must not exclude a tool that a remaining tool depends on"},
+		{Code: RuleRedundantTool, Name: "redundant-inherited-tool", Severity: SeverityWarning, Description: "Profiles should not list tools they already inherit through extends"},
+		{Code: RuleShadowedProfile, Name: "shadowed-builtin-profile", Severity: SeverityWarning, Description: "User profiles replace builtin profiles of the same name"},
+		{Code: RuleUnusedProfile, Name: "unused-profile", Severity: SeverityWarning, Description: "User profiles should be extended or selected as the default profile"},
+		{Code: RuleImpliedTool, Name: "implied-dependency", Severity: SeverityWarning, Description: "Tools already installed as dependencies of other listed tools need not be listed"},
+		{Code: RuleUnusedExclude, Name: "unused-exclude", Severity: SeverityWarning, Description: "Profile excludes should match a tool the profile would otherwise include"},
+		{Code: RuleUnselectedTool, Name: "unselected-tool", Severity: SeverityWarning, Description: "Tools defined in the tools: map should be selected by a profile, as defining a tool does not select it"},
+	}
+}
+
+// LintManifest runs every manifest check and returns all diagnostics, ordered
+// by position. file is recorded on each diagnostic and may be empty.
+func LintManifest(m Manifest, file string, catalog map[string]ToolSpec, builtinProfiles map[string]Profile) []Diagnostic {
+	l := &linter{manifest: m, file: file}
+
+	if m.APIVersion == "" {
+		l.report(RuleAPIVersion, SeverityError, "apiVersion", "apiVersion is required")
+	} else if m.APIVersion != "v1" {
+		l.report(RuleAPIVersion, SeverityError, "apiVersion", fmt.Sprintf("unsupported apiVersion %q", m.APIVersion))
+	}
+
+	catalog = MergeCatalog(catalog, m.ToolSpecs)
+	for _, id := range SortedToolIDs(m.ToolSpecs) {
+		if err := validateToolSpec(id, m.ToolSpecs[id], catalog); err != nil {
+			l.report(RuleToolDefinition, SeverityError, "tools."+id, err.Error())
+		}
+	}
+	for idx, tool := range m.Tools {
+		if _, ok := catalog[tool]; !ok {
+			l.report(RuleUnknownTool, SeverityError, l.toolPath(idx, tool), fmt.Sprintf("unknown tool %q", tool))
+		}
+	}
+
+	profiles := mergeProfiles(builtinProfiles, m.Profiles)
+	names := SupportedProfiles(profiles)
+	known := map[string]Profile{}
+	for _, name := range names {
+		profile := profiles[name]
+		resolvable := Profile{Tools: profile.Tools, Exclude: profile.Exclude}
+		for idx, base := range profile.Extends {
+			if _, ok := profiles[base]; !ok {
+				l.report(RuleUnknownProfile, SeverityError, fmt.Sprintf("profiles.%s.extends[%d]", name, idx), fmt.Sprintf("profile %q extends unknown profile %q", name, base))
+				continue
+			}
+			resolvable.Extends = append(resolvable.Extends, base)
+		}
+		for idx, tool := range profile.Tools {
+			if _, ok := catalog[tool]; !ok {
+				l.report(RuleUnknownTool, SeverityError, fmt.Sprintf("profiles.%s.tools[%d]", name, idx), fmt.Sprintf("profile %q references unknown tool %q", name, tool))
+			}
+		}
+		known[name] = resolvable
+	}
+
+	cycles := profileCycles(names, known)
+	for _, name := range cycles {
+		l.report(RuleInheritance, SeverityError, "profiles."+name, fmt.Sprintf("profile inheritance cycle detected at %q", name))
+	}
+
+	l.checkImpliedTools("tools", m.Tools, catalog)
+	l.checkUnselectedTools(profiles)
+	extended := map[string]bool{}
+	for _, profile := range profiles {
+		for _, base := range profile.Extends {
+			extended[base] = true
+		}
+	}
+	for _, name := range SupportedProfiles(m.Profiles) {
+		profile := m.Profiles[name]
+		path := "profiles." + name
+		if _, ok := builtinProfiles[name]; ok {
+			l.report(RuleShadowedProfile, SeverityWarning, path, fmt.Sprintf("profile %q replaces the builtin profile of the same name", name))
+		}
+		if !extended[name] && name != m.Profile {
+			l.report(RuleUnusedProfile, SeverityWarning, path, fmt.Sprintf("profile %q is not extended by any profile and is not the default profile", name))
+		}
+		if len(cycles) == 0 {
+			l.checkRedundantTools(name, profile, known)
+			l.checkExcludes(name, profile, known, catalog)
+		}
+		l.checkImpliedTools(path+".tools", profile.Tools, catalog)
+	}
+
+	sortDiagnostics(l.diagnostics)
+	return l.diagnostics
+}
+
+// HasErrors reports whether any diagnostic is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// DiagnosticFromError converts a manifest load error into a syntax diagnostic,
+// keeping its position when one is known.
+func DiagnosticFromError(file string, err error) Diagnostic {
+	d := Diagnostic{Code: RuleSyntax, Severity: SeverityError, File: file, Message: err.Error()}
+	var manifestErr *ManifestError
+	if errors.As(err, &manifestErr) {
+		d.Line = manifestErr.Line
+		d.Column = manifestErr.Column
+	}
+	return d
+}
+
+type linter struct {
+	manifest    Manifest
+	file        string
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(code string, severity Severity, path string, message string) {
+	pos := l.manifest.positions[path]
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Code:     code,
+		Severity: severity,
+		File:     l.file,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  message,
+	})
+}
+
+func (l *linter) checkRedundantTools(name string, profile Profile, profiles map[string]Profile) {
+	inheritedFrom := map[string]string{}
+	for _, base := range profile.Extends {
+		tools, err := resolveProfile(base, profiles, nil, nil)
+		if err != nil {
+			continue
+		}
+		for _, tool := range tools {
+			if _, ok := inheritedFrom[tool]; !ok {
+				inheritedFrom[tool] = base
+			}
+		}
+	}
+	for idx, tool := range profile.Tools {
+		if base, ok := inheritedFrom[tool]; ok {
+			l.report(RuleRedundantTool, SeverityWarning, fmt.Sprintf("profiles.%s.tools[%d]", name, idx), fmt.Sprintf("profile %q lists tool %q already inherited from %q", name, tool, base))
+		}
+	}
+}
+
+func (l *linter) checkExcludes(name string, profile Profile, profiles map[string]Profile, catalog map[string]ToolSpec) {
+	candidates := map[string]bool{}
+	for _, base := range profile.Extends {
+		tools, _ := resolveProfile(base, profiles, nil, nil)
+		for _, tool := range tools {
+			candidates[tool] = true
+		}
+	}
+	for _, tool := range profile.Tools {
+		candidates[tool] = true
+	}
+	for idx, tool := range profile.Exclude {
+		if !candidates[tool] {
+			l.report(RuleUnusedExclude, SeverityWarning, fmt.Sprintf("profiles.%s.exclude[%d]", name, idx), fmt.Sprintf("profile %q excludes tool %q, which it never includes", name, tool))
+		}
+	}
+
+	violation, ok := findExcludedDependency(name, profiles, catalog)
+	if !ok || violation.ExcludedBy != name {
+		return
+	}
+	path := "profiles." + name
+	if idx := slices.Index(profile.Exclude, violation.Tool); idx >= 0 {
+		path = fmt.Sprintf("%s.exclude[%d]", path, idx)
+	}
+	l.report(RuleExcludedDep, SeverityError, path, violation.Error())
+}
+
+// checkUnselectedTools warns about tools defined in the map form of tools:
+// that no profile selects. A manifest uses either the list form, which
+// selects tools, or the map form, which only defines them, so a defined tool
+// is installed only when a profile lists it.
+func (l *linter) checkUnselectedTools(profiles map[string]Profile) {
+	selected := map[string]bool{}
+	for _, profile := range profiles {
+		for _, tool := range profile.Tools {
+			selected[tool] = true
+		}
+	}
+	for _, id := range SortedToolIDs(l.manifest.ToolSpecs) {
+		if !selected[id] {
+			l.report(RuleUnselectedTool, SeverityWarning, "tools."+id, fmt.Sprintf("tool %q is defined under tools: but no profile selects it; the map form of tools: only defines tools, so list it in a profile's tools", id))
+		}
+	}
+}
+
+// checkImpliedTools warns about tools that another tool in the same list
+// already pulls in through its dependencies.
+func (l *linter) checkImpliedTools(path string, tools []string, catalog map[string]ToolSpec) {
+	for idx, tool := range tools {
+		for _, other := range tools {
+			if other == tool {
+				continue
+			}
+			if dependencyClosure(other, catalog)[tool] {
+				l.report(RuleImpliedTool, SeverityWarning, l.listItemPath(path, idx, tool), fmt.Sprintf("tool %q is already installed as a dependency of %q", tool, other))
+				break
+			}
+		}
+	}
+}
+
+func (l *linter) listItemPath(path string, idx int, tool string) string {
+	if path == "tools" {
+		return l.toolPath(idx, tool)
+	}
+	return fmt.Sprintf("%s[%d]", path, idx)
+}
+
+func (l *linter) toolPath(idx int, tool string) string {
+	if _, ok := l.manifest.positions["tools."+tool]; ok {
+		return "tools." + tool
+	}
+	return fmt.Sprintf("tools[%d]", idx)
+}
+
+// profileCycles returns, once per cycle, the profile at which a depth-first
+// walk in names order re-enters a profile it is still resolving.
+func profileCycles(names []string, profiles map[string]Profile) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	cycles := []string{}
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		for _, base := range profiles[name].Extends {
+			switch state[base] {
+			case visiting:
+				cycles = append(cycles, base)
+			case unvisited:
+				visit(base)
+			}
+		}
+		state[name] = done
+	}
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+	return cycles
+}
+
+// sortDiagnostics orders by position; diagnostics without a position (for
+// example on builtin profiles) keep their relative order at the end.
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return a.Line != 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// dependencyClosure returns every tool id reachable from id through
+// dependencies, excluding id itself.
+func dependencyClosure(id string, catalog map[string]ToolSpec) map[string]bool {
+	out := map[string]bool{}
+	stack := append([]string{}, catalog[id].Dependencies...)
+	for len(stack) > 0 {
+		dep := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if out[dep] || dep == id {
+			continue
+		}
+		out[dep] = true
+		stack = append(stack, catalog[dep].Dependencies...)
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package dynamic
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintManifestCollectsEveryProblemWithPositions(t *testing.T) {
+	content := `apiVersion: v2
+tools: [go, not-real]
+profiles:
+  loop-a:
+    extends: [loop-b]
+  loop-b:
+    extends: [loop-a, missing]
+    tools: [ghost]
+`
+	path := filepath.Join(t.TempDir(), "prepare.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+
+	diagnostics := LintManifest(m, path, BuiltinCatalog(), BuiltinProfiles())
+	want := []Diagnostic{
+		{Code: RuleAPIVersion, Line: 1, Column: 1},
+		{Code: RuleUnknownTool, Line: 2, Column: 13},
+		{Code: RuleInheritance, Line: 4, Column: 3},
+		{Code: RuleUnknownProfile, Line: 7, Column: 23},
+		{Code: RuleUnknownTool, Line: 8, Column: 13},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %#v", len(want), diagnostics)
+	}
+	for i, w := range want {
+		got := diagnostics[i]
+		if got.Code != w.Code || got.Line != w.Line || got.Column != w.Column || got.File != path || got.Severity != SeverityError {
+			t.Fatalf("diagnostic %d = %#v, want code %s at %d:%d", i, got, w.Code, w.Line, w.Column)
+		}
+	}
+}
+
+func TestBuildSARIFIncludesRulesAndRegions(t *testing.T) {
+	log := BuildSARIF([]Diagnostic{{Code: RuleUnknownTool, Severity: SeverityError, File: "prepare.yaml", Line: 3, Column: 5, Message: "unknown tool"}})
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected SARIF log: %#v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(LintRules()) {
+		t.Fatalf("expected every lint rule in driver, got %d", len(run.Tool.Driver.Rules))
+	}
+	result := run.Results[0]
+	region := result.Locations[0].PhysicalLocation.Region
+	if result.RuleID != RuleUnknownTool || region == nil || region.StartLine != 3 || region.StartColumn != 5 {
+		t.Fatalf("unexpected SARIF result: %#v", result)
+	}
+}
+
+func TestLintManifestWarnsAboutProfileDrift(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "team",
+		Tools:      []string{"homebrew", "go"},
+		Profiles: map[string]Profile{
+			"team":     {Extends: []string{"backend"}, Tools: []string{"git", "nodejs"}},
+			"frontend": {Tools: []string{"nodejs"}},
+			"scratch":  {Tools: []string{"dotnet"}},
+		},
+	}
+
+	got := map[string][]string{}
+	for _, d := range LintManifest(m, "", BuiltinCatalog(), BuiltinProfiles()) {
+		if d.Severity != SeverityWarning {
+			t.Fatalf("expected only warnings, got %#v", d)
+		}
+		got[d.Code] = append(got[d.Code], d.Message)
+	}
+	want := map[string][]string{
+		RuleImpliedTool:     {`tool "homebrew" is already installed as a dependency of "go"`},
+		RuleShadowedProfile: {`profile "frontend" replaces the builtin profile of the same name`},
+		RuleUnusedProfile:   {`profile "scratch" is not extended by any profile and is not the default profile`},
+		RuleRedundantTool:   {`profile "team" lists tool "git" already inherited from "backend"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected warnings:\n got %#v\nwant %#v", got, want)
+	}
+	if err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles()); err != nil {
+		t.Fatalf("warnings must not fail validation: %v", err)
+	}
+}
+
+func TestLintManifestWarnsAboutUnmatchedExclude(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "lean",
+		Profiles: map[string]Profile{
+			"lean": {Extends: []string{"backend"}, Exclude: []string{"docker", "iterm2"}},
+		},
+	}
+	diagnostics := LintManifest(m, "", BuiltinCatalog(), BuiltinProfiles())
+	if len(diagnostics) != 1 || diagnostics[0].Code != RuleUnusedExclude {
+		t.Fatalf("expected one unused exclude warning, got %#v", diagnostics)
+	}
+	if !strings.Contains(diagnostics[0].Message, `"iterm2"`) {
+		t.Fatalf("unexpected message: %s", diagnostics[0].Message)
+	}
+}
+
+func TestLintManifestWarnsAboutUnselectedToolDefinitions(t *testing.T) {
+	acme := ToolSpec{Install: Command{Name: "go", Args: []string{"install", "example.com/acme@latest"}}, Check: Check{Binary: "acme"}}
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "team",
+		Profiles: map[string]Profile{
+			"team": {Extends: []string{"backend"}, Tools: []string{"acme-cli"}},
+		},
+		ToolSpecs: map[string]ToolSpec{"acme-cli": acme, "acme-lint": acme, "go": {Constraint: ">=1.22"}},
+	}
+	diagnostics := LintManifest(m, "", BuiltinCatalog(), BuiltinProfiles())
+	if len(diagnostics) != 1 || diagnostics[0].Code != RuleUnselectedTool || !strings.Contains(diagnostics[0].Message, `"acme-lint"`) {
+		t.Fatalf("expected one unselected tool warning for acme-lint, got %#v", diagnostics)
+	}
+}
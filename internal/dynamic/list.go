@@ -0,0 +1,71 @@
+package dynamic
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+)
+
+type ToolListing struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title"`
+	Installed bool     `json:"installed"`
+	Version   string   `json:"version,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Profiles  []string `json:"profiles"`
+}
+
+// List checks every tool in catalog and reports it with the profiles that
+// include it according to membership, ordered by id. Checks run
+// concurrently, since each may start a process.
+func (e *Executor) List(catalog map[string]ToolSpec, membership map[string][]string) []ToolListing {
+	ids := SortedToolIDs(catalog)
+	listings := make([]ToolListing, len(ids))
+	for i, id := range ids {
+		tool := catalog[id]
+		listings[i] = ToolListing{ID: id, Title: tool.Title, Source: tool.Source, Profiles: membership[id]}
+		if listings[i].Profiles == nil {
+			listings[i].Profiles = []string{}
+		}
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(len(ids), runtime.NumCPU()*2); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				status := e.inspect(catalog[ids[i]])
+				listings[i].Installed = status.Reason != ReasonNotInstalled
+				listings[i].Version = status.Version
+			}
+		}()
+	}
+	for i := range ids {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return listings
+}
+
+// ToolProfiles maps each tool to the sorted names of the builtin and
+// manifest profiles that resolve to it. Profiles that fail to resolve are
+// ignored.
+func ToolProfiles(m Manifest, builtinProfiles map[string]Profile) map[string][]string {
+	profiles := mergeProfiles(builtinProfiles, m.Profiles)
+	out := map[string][]string{}
+	for _, name := range SupportedProfiles(profiles) {
+		tools, err := resolveProfile(name, profiles, nil, nil)
+		if err != nil {
+			continue
+		}
+		for _, tool := range tools {
+			if !slices.Contains(out[tool], name) {
+				out[tool] = append(out[tool], name)
+			}
+		}
+	}
+	return out
+}
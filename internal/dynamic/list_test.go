@@ -0,0 +1,64 @@
+package dynamic
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListReportsStatusAndProfiles(t *testing.T) {
+	probe := func(name string) Check {
+		return Check{Binary: name, Version: &VersionProbe{Command: Command{Name: name}, Pattern: `(.*)`}}
+	}
+	catalog := map[string]ToolSpec{
+		"git":    {ID: "git", Title: "Git", Source: "homebrew/homebrew-core", Check: probe("git")},
+		"go":     {ID: "go", Title: "Go", Check: probe("go")},
+		"docker": {ID: "docker", Title: "Docker", Check: Check{Binary: "docker"}},
+	}
+	executor := newTestExecutor(map[string]string{"git": "2.44.0", "docker": ""})
+	manifest := Manifest{Profiles: map[string]Profile{
+		"team": {Extends: []string{"base"}, Tools: []string{"go"}},
+	}}
+	membership := ToolProfiles(manifest, map[string]Profile{"base": {Tools: []string{"git", "go"}}})
+
+	listings := executor.List(catalog, membership)
+	got := []string{}
+	for _, l := range listings {
+		got = append(got, fmt.Sprintf("%s %v %s %s", l.ID, l.Installed, l.Version, strings.Join(l.Profiles, ",")))
+	}
+	want := []string{"docker true  ", "git true 2.44.0 base,team", "go false  base,team"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected listings:\n got %q\nwant %q", got, want)
+	}
+	if listings[1].Source != "homebrew/homebrew-core" || listings[0].Profiles == nil {
+		t.Fatalf("unexpected git listing: %#v", listings[1])
+	}
+}
+
+func TestListRunsChecksConcurrently(t *testing.T) {
+	catalog := map[string]ToolSpec{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		catalog[id] = ToolSpec{ID: id, Check: Check{Binary: id}}
+	}
+	executor := newTestExecutor(nil)
+	var running, peak atomic.Int32
+	executor.checkTool = func(c Check) bool {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return false
+	}
+
+	executor.List(catalog, nil)
+	if peak.Load() < 2 {
+		t.Fatalf("expected checks to overlap, peak concurrency was %d", peak.Load())
+	}
+}
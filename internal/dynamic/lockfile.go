@@ -1,10 +1,22 @@
 package dynamic
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
 	"sort"
+	"strings"
 	"time"
 )
 
+const LockfileVersion = 2
+
+// BuildLockfile records the tools of plan. Tools asking for a moving version
+// such as "latest" or "lts" get a concrete version from ResolveVersions once
+// they have been probed on the machine.
 func BuildLockfile(plan Plan) Lockfile {
 	tools := make([]LockedTool, 0, len(plan.Steps))
 	seen := map[string]bool{}
@@ -13,16 +25,148 @@ func BuildLockfile(plan Plan) Lockfile {
 			continue
 		}
 		seen[step.Tool.ID] = true
+		version := step.Tool.Version
+		if isMovingVersion(version) {
+			version = ""
+		}
 		tools = append(tools, LockedTool{
-			ID:      step.Tool.ID,
-			Version: step.Tool.Version,
-			Source:  step.Tool.Source,
+			ID:         step.Tool.ID,
+			Version:    version,
+			Requested:  step.Tool.Version,
+			Constraint: step.Tool.Constraint,
+			Source:     step.Tool.Source,
 		})
 	}
 	sort.Slice(tools, func(i, j int) bool { return tools[i].ID < tools[j].ID })
 	return Lockfile{
-		Version:     1,
+		Version:     LockfileVersion,
 		GeneratedAt: time.Now(),
+		Platform:    CurrentPlatform(),
+		CatalogHash: PlanCatalogHash(plan),
 		Tools:       tools,
 	}
 }
+
+// ResolveVersions records the installed version of each tool found in
+// versions. Tools without a probed version keep an empty version.
+func (l *Lockfile) ResolveVersions(versions map[string]string) {
+	for i := range l.Tools {
+		if v, ok := versions[l.Tools[i].ID]; ok && v != "" {
+			l.Tools[i].Version = v
+		}
+	}
+}
+
+// InstalledVersions collects the versions probed during a run.
+func InstalledVersions(result ExecutionResult) map[string]string {
+	versions := map[string]string{}
+	for _, step := range result.Steps {
+		if step.Success && step.Version != "" {
+			versions[step.ToolID] = step.Version
+		}
+	}
+	return versions
+}
+
+func LoadLockfile(path string) (Lockfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Lockfile{}, err
+	}
+	var lock Lockfile
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return Lockfile{}, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+	if lock.Version > LockfileVersion {
+		return Lockfile{}, fmt.Errorf("unsupported lockfile version %d", lock.Version)
+	}
+	return lock, nil
+}
+
+func isMovingVersion(version string) bool {
+	switch strings.ToLower(version) {
+	case "", "latest", "lts", "stable":
+		return true
+	}
+	return false
+}
+
+func CurrentPlatform() Platform {
+	return Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+}
+
+// PlanCatalogHash hashes the catalog entries the plan was built from, so two
+// lockfiles only share it when every planned tool is defined identically.
+func PlanCatalogHash(plan Plan) string {
+	specs := make(map[string]ToolSpec, len(plan.Steps))
+	for _, step := range plan.Steps {
+		specs[step.Tool.ID] = step.Tool
+	}
+	b, err := json.Marshal(specs)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(b)
+}
+
+// Digest returns the sha256 of the manifest file the manifest was loaded
+// from, or an empty string for the builtin default manifest.
+func (m Manifest) Digest() string {
+	return m.digest
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// FrozenPlan builds a plan from the tools recorded in lock instead of
+// resolving profiles. Each locked version becomes the tool's version and an
+// exact constraint, and tools with a PinnedInstall install that version (as
+// ${version}) rather than the latest one.
+func FrozenPlan(lock Lockfile, catalog map[string]ToolSpec) (Plan, error) {
+	pinned := make(map[string]ToolSpec, len(catalog))
+	for id, spec := range catalog {
+		pinned[id] = spec
+	}
+	ids := make([]string, 0, len(lock.Tools))
+	for _, locked := range lock.Tools {
+		spec, ok := pinned[locked.ID]
+		if !ok {
+			return Plan{}, fmt.Errorf("locked tool %q is not in the catalog", locked.ID)
+		}
+		if _, err := ParseVersion(locked.Version); err == nil {
+			spec.Version = locked.Version
+			spec.Constraint = "=" + locked.Version
+			if spec.PinnedInstall != nil {
+				// Keep the package-manager lock the regular install takes.
+				spec.Lock = lockFor(spec, spec.Install)
+				spec.Install = *spec.PinnedInstall
+			}
+		}
+		pinned[locked.ID] = spec
+		ids = append(ids, locked.ID)
+	}
+	return BuildPlan(ids, pinned)
+}
+
+// CheckPinnable fails when a frozen plan would install a locked version of
+// a tool that has no PinnedInstall, since its regular install cannot yield
+// that version. Tools already installed at the locked version are fine.
+func (e *Executor) CheckPinnable(plan Plan) error {
+	var unpinned []string
+	for _, step := range plan.Steps {
+		tool := step.Tool
+		if tool.PinnedInstall != nil || !strings.HasPrefix(tool.Constraint, "=") {
+			continue
+		}
+		if e.inspect(tool).Reason == ReasonAlreadyInstalled {
+			continue
+		}
+		unpinned = append(unpinned, tool.ID+"@"+tool.Version)
+	}
+	if len(unpinned) > 0 {
+		return fmt.Errorf("cannot install locked versions of tools without a pinnedInstall: %s", strings.Join(unpinned, ", "))
+	}
+	return nil
+}
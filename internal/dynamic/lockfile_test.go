@@ -0,0 +1,161 @@
+package dynamic
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBuildLockfileResolvesInstalledVersions(t *testing.T) {
+	plan := Plan{Steps: []PlanStep{
+		{Order: 1, Tool: ToolSpec{ID: "go", Version: "latest", Source: "brew:go"}},
+		{Order: 2, Tool: ToolSpec{ID: "nodejs", Version: "lts", Source: "brew:node"}},
+		{Order: 3, Tool: ToolSpec{ID: "git", Version: "2.44.0", Source: "brew:git"}},
+	}}
+	result := ExecutionResult{Steps: []ExecutionStep{
+		{ToolID: "go", Action: "install", Success: true, Version: "1.23.4"},
+		{ToolID: "nodejs", Action: "skip", Success: true, Version: "20.11.1"},
+		{ToolID: "git", Action: "skip", Success: true},
+	}}
+
+	lock := BuildLockfile(plan)
+	lock.ResolveVersions(InstalledVersions(result))
+
+	want := map[string]LockedTool{
+		"go":     {ID: "go", Version: "1.23.4", Requested: "latest", Source: "brew:go"},
+		"nodejs": {ID: "nodejs", Version: "20.11.1", Requested: "lts", Source: "brew:node"},
+		"git":    {ID: "git", Version: "2.44.0", Requested: "2.44.0", Source: "brew:git"},
+	}
+	for _, tool := range lock.Tools {
+		if tool != want[tool.ID] {
+			t.Fatalf("unexpected locked tool: got %#v, want %#v", tool, want[tool.ID])
+		}
+	}
+	if lock.Version != LockfileVersion {
+		t.Fatalf("expected lockfile version %d, got %d", LockfileVersion, lock.Version)
+	}
+	if lock.Platform.OS != runtime.GOOS || lock.Platform.Arch != runtime.GOARCH {
+		t.Fatalf("unexpected platform: %#v", lock.Platform)
+	}
+}
+
+func TestPlanCatalogHashTracksToolDefinitions(t *testing.T) {
+	plan := Plan{Steps: []PlanStep{{Order: 1, Tool: ToolSpec{ID: "go", Install: Command{Name: "brew", Args: []string{"install", "go"}}}}}}
+	hash := PlanCatalogHash(plan)
+	if hash == "" || hash != PlanCatalogHash(plan) {
+		t.Fatalf("expected a stable catalog hash, got %q", hash)
+	}
+	plan.Steps[0].Tool.Install.Args = []string{"install", "go@1.22"}
+	if PlanCatalogHash(plan) == hash {
+		t.Fatal("expected catalog hash to change with the tool definition")
+	}
+}
+
+func TestManifestDigestHashesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prepare.yaml")
+	if err := os.WriteFile(path, []byte("apiVersion: v1\ntools: [git]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("apiVersion: v1\ntools: [git, go]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+	if a.Digest() == "" || a.Digest() == b.Digest() {
+		t.Fatalf("expected distinct manifest digests, got %q and %q", a.Digest(), b.Digest())
+	}
+}
+
+func TestFrozenPlanPinsLockedVersions(t *testing.T) {
+	catalog := map[string]ToolSpec{
+		"go": {
+			ID:            "go",
+			Install:       Command{Name: "brew", Args: []string{"install", "go"}},
+			PinnedInstall: &Command{Name: "goenv", Args: []string{"install", "${version}"}},
+		},
+		"git":    {ID: "git", Install: Command{Name: "brew", Args: []string{"install", "git"}}},
+		"docker": {ID: "docker", Install: Command{Name: "brew", Args: []string{"install", "docker"}}},
+	}
+	lock := Lockfile{Tools: []LockedTool{{ID: "git", Version: "2.44.0"}, {ID: "go", Version: "1.22.1"}}}
+
+	plan, err := FrozenPlan(lock, catalog)
+	if err != nil {
+		t.Fatalf("FrozenPlan error: %v", err)
+	}
+	steps := map[string]ToolSpec{}
+	for _, step := range plan.Steps {
+		steps[step.Tool.ID] = step.Tool
+	}
+	if len(steps) != 2 {
+		t.Fatalf("expected only locked tools in plan, got %#v", steps)
+	}
+	if steps["go"].Constraint != "=1.22.1" || steps["go"].Version != "1.22.1" || steps["go"].Install.Name != "goenv" {
+		t.Fatalf("expected go pinned to 1.22.1, got %#v", steps["go"])
+	}
+	if steps["git"].Constraint != "=2.44.0" || steps["git"].Install.Args[1] != "git" {
+		t.Fatalf("expected git constrained with its regular install, got %#v", steps["git"])
+	}
+	if lockFor(steps["go"], steps["go"].Install) != "brew" {
+		t.Fatalf("expected pinned go install to keep the brew lock, got %#v", steps["go"])
+	}
+	if catalog["go"].Install.Name != "brew" {
+		t.Fatal("expected FrozenPlan not to modify the catalog")
+	}
+
+	lock.Tools = append(lock.Tools, LockedTool{ID: "rust"})
+	if _, err := FrozenPlan(lock, catalog); err == nil {
+		t.Fatal("expected error for locked tool missing from the catalog")
+	}
+}
+
+func TestCheckPinnableRejectsUnpinnedInstalls(t *testing.T) {
+	probe := func(name string) Check {
+		return Check{Binary: name, Version: &VersionProbe{Command: Command{Name: name}, Pattern: `(.*)`}}
+	}
+	catalog := map[string]ToolSpec{
+		"go":     {ID: "go", Check: probe("go"), PinnedInstall: &Command{Name: "goenv", Args: []string{"install", "${version}"}}},
+		"git":    {ID: "git", Check: probe("git")},
+		"docker": {ID: "docker", Check: probe("docker")},
+	}
+	lock := Lockfile{Tools: []LockedTool{{ID: "go", Version: "1.22.1"}, {ID: "git", Version: "2.44.0"}, {ID: "docker", Version: "26.1.0"}}}
+	plan, err := FrozenPlan(lock, catalog)
+	if err != nil {
+		t.Fatalf("FrozenPlan error: %v", err)
+	}
+
+	executor := newTestExecutor(map[string]string{"git": "2.44.0", "docker": "25.0.0"})
+	err = executor.CheckPinnable(plan)
+	if err == nil || !strings.Contains(err.Error(), "docker@26.1.0") || strings.Contains(err.Error(), "git") || strings.Contains(err.Error(), "go@") {
+		t.Fatalf("expected only docker to be reported, got %v", err)
+	}
+
+	executor = newTestExecutor(map[string]string{"git": "2.44.0", "docker": "26.1.0"})
+	if err := executor.CheckPinnable(plan); err != nil {
+		t.Fatalf("expected installed locked versions to pass, got %v", err)
+	}
+}
+
+func TestBuiltinPinnedInstallsExtractFormulae(t *testing.T) {
+	for _, id := range []string{"git", "go", "nodejs", "zsh", "docker"} {
+		spec := BuiltinCatalog()[id]
+		if spec.PinnedInstall == nil {
+			t.Fatalf("expected %s to be pinnable", id)
+		}
+		c, err := expandCommand(*spec.PinnedInstall, map[string]string{"id": id, "version": "1.2.3"})
+		if err != nil {
+			t.Fatalf("expand %s: %v", id, err)
+		}
+		if c.Shell == "" || c.Args[1] != "1.2.3" || !strings.Contains(c.Name, "brew extract") {
+			t.Fatalf("unexpected pinned install for %s: %#v", id, c)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package dynamic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"felipewom/go-env-prepare/internal/progress"
+)
+
+// outputTailLines is how many trailing output lines a failed step keeps in
+// ExecutionStep.OutputTail.
+const outputTailLines = 20
+
+// DefaultLogDir returns $XDG_STATE_HOME/go-env-prepare/logs, falling back to
+// ~/.local/state/go-env-prepare/logs.
+func DefaultLogDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "go-env-prepare", "logs")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "go-env-prepare", "logs")
+}
+
+func newRunID(t time.Time) string {
+	return fmt.Sprintf("%s-%d", t.Format("20060102-150405"), os.Getpid())
+}
+
+// captureOutput runs fn with a writer that appends to the tool's log file in
+// logDir (when set), passes every complete line to onLine (when set), and
+// keeps a tail that is stored on step if fn fails.
+func captureOutput(logDir string, toolID string, step *ExecutionStep, onLine func(line string), fn func(out io.Writer) error) error {
+	tail := &tailWriter{max: outputTailLines}
+	writers := []io.Writer{tail}
+	if logDir != "" {
+		path := filepath.Join(logDir, logFileName(toolID))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open step log: %w", err)
+		}
+		defer f.Close()
+		step.LogPath = path
+		writers = append(writers, f)
+	}
+	var lines *progress.LineWriter
+	if onLine != nil {
+		lines = progress.NewLineWriter(onLine)
+		writers = append(writers, lines)
+	}
+
+	err := fn(io.MultiWriter(writers...))
+	if lines != nil {
+		lines.Close()
+	}
+	if err != nil {
+		step.OutputTail = tail.String()
+	}
+	return err
+}
+
+// prefixLines returns a line handler that writes each line to w prefixed
+// with the tool id, so output of parallel steps stays readable.
+func prefixLines(w io.Writer, toolID string) func(line string) {
+	return func(line string) {
+		_, _ = fmt.Fprintf(w, "[%s] %s\n", toolID, line)
+	}
+}
+
+// logFileName keeps tool ids that are not plain names from escaping the
+// run's log directory.
+func logFileName(toolID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, toolID)
+	return strings.TrimLeft(name, ".") + ".log"
+}
+
+// tailWriter keeps the last max lines written to it.
+type tailWriter struct {
+	max   int
+	lines []string
+	part  []byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.part = append(t.part, p...)
+	for {
+		idx := bytes.IndexByte(t.part, '\n')
+		if idx < 0 {
+			break
+		}
+		t.lines = append(t.lines, string(t.part[:idx]))
+		t.part = t.part[idx+1:]
+		if len(t.lines) > t.max {
+			t.lines = t.lines[len(t.lines)-t.max:]
+		}
+	}
+	return len(p), nil
+}
+
+func (t *tailWriter) String() string {
+	lines := t.lines
+	if len(t.part) > 0 {
+		lines = append(append([]string{}, lines...), string(t.part))
+		if len(lines) > t.max {
+			lines = lines[len(lines)-t.max:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// syncWriter serializes writes from concurrent steps.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
@@ -1,12 +1,13 @@
 package dynamic
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 )
@@ -24,6 +25,8 @@ func DiscoverManifestPath(explicitPath string) (string, error) {
 	return "", os.ErrNotExist
 }
 
+// LoadManifest reads a manifest file. A .json file is decoded as JSON; any
+// other file is YAML, which also accepts JSON and YAML flow mappings.
 func LoadManifest(path string) (Manifest, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -31,12 +34,12 @@ func LoadManifest(path string) (Manifest, error) {
 	}
 	trimmed := strings.TrimSpace(string(b))
 	if trimmed == "" {
-		return Manifest{APIVersion: "v1"}, nil
+		return Manifest{APIVersion: "v1", digest: hashBytes(b)}, nil
 	}
-	if strings.HasPrefix(trimmed, "{") {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
 		var m Manifest
 		if err := json.Unmarshal([]byte(trimmed), &m); err != nil {
-			return Manifest{}, fmt.Errorf("invalid JSON manifest: %w", err)
+			return Manifest{}, jsonManifestError(b, err)
 		}
 		if m.APIVersion == "" {
 			m.APIVersion = "v1"
@@ -44,121 +47,128 @@ func LoadManifest(path string) (Manifest, error) {
 		if m.Profiles == nil {
 			m.Profiles = map[string]Profile{}
 		}
+		m.positions = yamlPositions(b)
+		m.digest = hashBytes(b)
 		return m, nil
 	}
-	return parseYAMLManifest(trimmed)
+	m, err := parseYAMLManifest(b)
+	if err != nil {
+		return Manifest{}, err
+	}
+	m.positions = yamlPositions(b)
+	m.digest = hashBytes(b)
+	return m, nil
 }
 
-func parseYAMLManifest(content string) (Manifest, error) {
-	m := Manifest{APIVersion: "v1", Profiles: map[string]Profile{}}
-	s := bufio.NewScanner(strings.NewReader(content))
-	var section string
-	var inProfile string
-	var profileField string
-	lineNo := 0
+func (m *Manifest) UnmarshalJSON(b []byte) error {
+	type manifestFields Manifest
+	var raw struct {
+		manifestFields
+		Tools json.RawMessage `json:"tools,omitempty"`
+	}
+	if err := decodeJSONStrict(b, &raw); err != nil {
+		return err
+	}
+	*m = Manifest(raw.manifestFields)
 
-	for s.Scan() {
-		lineNo++
-		raw := s.Text()
-		trimmed := strings.TrimSpace(stripComment(raw))
-		if trimmed == "" {
-			continue
+	tools := strings.TrimSpace(string(raw.Tools))
+	switch {
+	case tools == "" || tools == "null":
+		return nil
+	case strings.HasPrefix(tools, "{"):
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(raw.Tools, &entries); err != nil {
+			return fmt.Errorf("tools: %w", err)
 		}
-		indent := leadingSpaces(raw)
-
-		switch {
-		case indent == 0 && strings.HasPrefix(trimmed, "apiVersion:"):
-			m.APIVersion = strings.TrimSpace(strings.TrimPrefix(trimmed, "apiVersion:"))
-			section = ""
-			inProfile = ""
-		case indent == 0 && strings.HasPrefix(trimmed, "profile:"):
-			m.Profile = strings.TrimSpace(strings.TrimPrefix(trimmed, "profile:"))
-			section = ""
-			inProfile = ""
-		case indent == 0 && trimmed == "tools:":
-			section = "tools"
-			inProfile = ""
-		case indent == 0 && trimmed == "profiles:":
-			section = "profiles"
-			inProfile = ""
-		case section == "tools" && indent == 2 && strings.HasPrefix(trimmed, "- "):
-			m.Tools = append(m.Tools, strings.TrimSpace(strings.TrimPrefix(trimmed, "- ")))
-		case section == "profiles" && indent == 2 && strings.HasSuffix(trimmed, ":"):
-			inProfile = strings.TrimSuffix(trimmed, ":")
-			if _, exists := m.Profiles[inProfile]; !exists {
-				m.Profiles[inProfile] = Profile{}
-			}
-			profileField = ""
-		case section == "profiles" && inProfile != "" && indent == 4 && strings.HasPrefix(trimmed, "tools:"):
-			profileField = "tools"
-		case section == "profiles" && inProfile != "" && indent == 4 && strings.HasPrefix(trimmed, "extends:"):
-			profileField = "extends"
-		case section == "profiles" && inProfile != "" && indent == 6 && strings.HasPrefix(trimmed, "- "):
-			p := m.Profiles[inProfile]
-			item := strings.TrimSpace(strings.TrimPrefix(trimmed, "- "))
-			if profileField == "tools" {
-				p.Tools = append(p.Tools, item)
-			} else if profileField == "extends" {
-				p.Extends = append(p.Extends, item)
-			} else {
-				return Manifest{}, fmt.Errorf("line %d: list item outside profile tools/extends", lineNo)
+		for id, entry := range entries {
+			var spec ToolSpec
+			var constraint string
+			switch trimmed := strings.TrimSpace(string(entry)); {
+			case trimmed == "null":
+				return &ManifestError{Key: "tools." + id, Msg: emptyToolDefinition}
+			case strings.HasPrefix(trimmed, `"`):
+				if err := json.Unmarshal(entry, &constraint); err != nil {
+					return fmt.Errorf("tools.%s: %w", id, err)
+				}
+				spec.Constraint = constraint
+			default:
+				if err := decodeJSONStrict(entry, &spec); err != nil {
+					return fmt.Errorf("tools.%s: %w", id, err)
+				}
 			}
-			m.Profiles[inProfile] = p
-		default:
-			return Manifest{}, fmt.Errorf("line %d: unsupported manifest syntax", lineNo)
+			m.addToolSpec(id, spec)
 		}
+		return nil
+	default:
+		if err := json.Unmarshal(raw.Tools, &m.Tools); err != nil {
+			return fmt.Errorf("tools: %w", err)
+		}
+		return nil
 	}
-	if err := s.Err(); err != nil {
-		return Manifest{}, err
-	}
-	return m, nil
 }
 
-func stripComment(s string) string {
-	if idx := strings.Index(s, "#"); idx >= 0 {
-		return s[:idx]
-	}
-	return s
+// decodeJSONStrict decodes b into v, rejecting keys that v does not define
+// the same way the YAML decoder does.
+func decodeJSONStrict(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
 }
 
-func leadingSpaces(s string) int {
-	i := 0
-	for i < len(s) && s[i] == ' ' {
-		i++
-	}
-	return i
-}
+var jsonUnknownFieldPattern = regexp.MustCompile(`^(?:(tools\.[^:]+): )?json: unknown field "([^"]+)"$`)
 
-func ValidateManifest(m Manifest, catalog map[string]ToolSpec, builtinProfiles map[string]Profile) error {
-	if m.APIVersion == "" {
-		return errors.New("apiVersion is required")
+// jsonManifestError reports an unknown key in a JSON manifest with its
+// position and key path, like the YAML decoder's errors.
+func jsonManifestError(content []byte, err error) error {
+	var manifestErr *ManifestError
+	if errors.As(err, &manifestErr) {
+		pos := yamlPositions(content)[manifestErr.Key]
+		return &ManifestError{Line: pos.Line, Column: pos.Column, Key: manifestErr.Key, Msg: manifestErr.Msg}
 	}
-	if m.APIVersion != "v1" {
-		return fmt.Errorf("unsupported apiVersion %q", m.APIVersion)
+	match := jsonUnknownFieldPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("invalid JSON manifest: %w", err)
 	}
-	for _, tool := range m.Tools {
-		if _, ok := catalog[tool]; !ok {
-			return fmt.Errorf("unknown tool %q", tool)
+	prefix, field := match[1], match[2]
+	positions := yamlPositions(content)
+	paths := make([]string, 0, len(positions))
+	for path := range positions {
+		if strings.HasSuffix("."+path, "."+field) && strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
 		}
 	}
-
-	profiles := mergeProfiles(builtinProfiles, m.Profiles)
-	for profileName, profile := range profiles {
-		for _, base := range profile.Extends {
-			if _, ok := profiles[base]; !ok {
-				return fmt.Errorf("profile %q extends unknown profile %q", profileName, base)
-			}
-		}
-		for _, tool := range profile.Tools {
-			if _, ok := catalog[tool]; !ok {
-				return fmt.Errorf("profile %q references unknown tool %q", profileName, tool)
-			}
+	if len(paths) == 0 {
+		return &ManifestError{Key: field, Msg: "unknown key"}
+	}
+	slices.SortFunc(paths, func(a, b string) int {
+		pa, pb := positions[a], positions[b]
+		if pa.Line != pb.Line {
+			return pa.Line - pb.Line
 		}
+		return pa.Column - pb.Column
+	})
+	pos := positions[paths[0]]
+	return &ManifestError{Line: pos.Line, Column: pos.Column, Key: paths[0], Msg: "unknown key"}
+}
+
+// emptyToolDefinition rejects a tools: map entry without a value, which
+// would otherwise define nothing without telling the author.
+const emptyToolDefinition = "empty tool definition (set a version constraint or a tool definition)"
+
+func (m *Manifest) addToolSpec(id string, spec ToolSpec) {
+	if m.ToolSpecs == nil {
+		m.ToolSpecs = map[string]ToolSpec{}
+	}
+	if spec.ID == "" {
+		spec.ID = id
 	}
+	m.ToolSpecs[id] = spec
+}
 
-	for name := range profiles {
-		if _, err := resolveProfile(name, profiles, nil, nil); err != nil {
-			return err
+func ValidateManifest(m Manifest, catalog map[string]ToolSpec, builtinProfiles map[string]Profile) error {
+	for _, d := range LintManifest(m, "", catalog, builtinProfiles) {
+		if d.Severity == SeverityError {
+			return errors.New(d.Message)
 		}
 	}
 	return nil
@@ -166,6 +176,7 @@ func ValidateManifest(m Manifest, catalog map[string]ToolSpec, builtinProfiles m
 
 func ResolveTools(m Manifest, selectedProfile string, catalog map[string]ToolSpec, builtinProfiles map[string]Profile) ([]string, error) {
 	profiles := mergeProfiles(builtinProfiles, m.Profiles)
+	catalog = MergeCatalog(catalog, m.ToolSpecs)
 	tools := append([]string{}, m.Tools...)
 
 	if selectedProfile == "" {
@@ -179,6 +190,9 @@ func ResolveTools(m Manifest, selectedProfile string, catalog map[string]ToolSpe
 		if err != nil {
 			return nil, err
 		}
+		if violation, ok := findExcludedDependency(selectedProfile, profiles, catalog); ok {
+			return nil, violation
+		}
 		tools = append(tools, resolved...)
 	}
 
@@ -219,16 +233,88 @@ func resolveProfile(name string, profiles map[string]Profile, visiting map[strin
 		resolved = append(resolved, baseTools...)
 	}
 	resolved = append(resolved, profile.Tools...)
+	resolved = slices.DeleteFunc(resolved, func(tool string) bool {
+		return slices.Contains(profile.Exclude, tool)
+	})
 	visiting[name] = false
 	visited[name] = true
 
 	return unique(resolved), nil
 }
 
+// excludedDependency describes an excluded tool that a tool remaining in the
+// profile still depends on.
+type excludedDependency struct {
+	Profile    string
+	Tool       string
+	Dependent  string
+	ExcludedBy string
+}
+
+func (e excludedDependency) Error() string {
+	return fmt.Sprintf("profile %q excludes tool %q, which %q depends on", e.Profile, e.Tool, e.Dependent)
+}
+
+// findExcludedDependency reports the first tool excluded by name or one of
+// the profiles it extends that a remaining tool still depends on. Excluding a
+// dependency is an error: exclude the dependent tool as well.
+func findExcludedDependency(name string, profiles map[string]Profile, catalog map[string]ToolSpec) (excludedDependency, bool) {
+	tools, err := resolveProfile(name, profiles, nil, nil)
+	if err != nil {
+		return excludedDependency{}, false
+	}
+	excludedBy := profileExcludes(name, profiles, map[string]bool{})
+	if len(excludedBy) == 0 {
+		return excludedDependency{}, false
+	}
+	included := map[string]bool{}
+	for _, tool := range tools {
+		included[tool] = true
+	}
+	for _, tool := range tools {
+		deps := dependencyClosure(tool, catalog)
+		ids := make([]string, 0, len(deps))
+		for dep := range deps {
+			ids = append(ids, dep)
+		}
+		slices.Sort(ids)
+		for _, dep := range ids {
+			if by, ok := excludedBy[dep]; ok && !included[dep] {
+				return excludedDependency{Profile: name, Tool: dep, Dependent: tool, ExcludedBy: by}, true
+			}
+		}
+	}
+	return excludedDependency{}, false
+}
+
+// profileExcludes maps every tool excluded by name or its ancestors to the
+// nearest profile that excludes it.
+func profileExcludes(name string, profiles map[string]Profile, seen map[string]bool) map[string]string {
+	out := map[string]string{}
+	if seen[name] {
+		return out
+	}
+	seen[name] = true
+	profile := profiles[name]
+	for _, base := range profile.Extends {
+		for tool, by := range profileExcludes(base, profiles, seen) {
+			out[tool] = by
+		}
+	}
+	for _, tool := range profile.Exclude {
+		out[tool] = name
+	}
+	return out
+}
+
 func mergeProfiles(builtin map[string]Profile, user map[string]Profile) map[string]Profile {
 	all := map[string]Profile{}
 	for k, v := range builtin {
-		all[k] = Profile{Extends: append([]string{}, v.Extends...), Tools: append([]string{}, v.Tools...)}
+		all[k] = Profile{
+			Extends: append([]string{}, v.Extends...),
+			Tools:   append([]string{}, v.Tools...),
+			Exclude: append([]string{}, v.Exclude...),
+		}
 	}
 	for k, v := range user {
 		all[k] = v
@@ -1,6 +1,14 @@
 package dynamic
 
-import "testing"
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestResolveToolsWithProfileInheritance(t *testing.T) {
 	m := Manifest{
@@ -40,3 +48,316 @@ func TestValidateManifestUnknownTool(t *testing.T) {
 		t.Fatal("expected validation error for unknown tool")
 	}
 }
+
+func TestLoadManifestYAMLFlowListsQuotesAndAnchors(t *testing.T) {
+	content := `apiVersion: "v1"
+profile: 'custom'
+tools: [go, "git"]
+profiles:
+  shared: &shared
+    tools:
+      - docker
+  custom:
+    <<: *shared
+    extends: [base]
+`
+	path := filepath.Join(t.TempDir(), "prepare.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+	if m.Profile != "custom" || !reflect.DeepEqual(m.Tools, []string{"go", "git"}) {
+		t.Fatalf("unexpected manifest: %#v", m)
+	}
+	custom := m.Profiles["custom"]
+	if !reflect.DeepEqual(custom.Extends, []string{"base"}) || !reflect.DeepEqual(custom.Tools, []string{"docker"}) {
+		t.Fatalf("unexpected merged profile: %#v", custom)
+	}
+}
+
+func TestLoadManifestYAMLFlowMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prepare.yaml")
+	if err := os.WriteFile(path, []byte("{apiVersion: v1, tools: [go, git]}\n"), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+	if m.APIVersion != "v1" || strings.Join(m.Tools, ",") != "go,git" {
+		t.Fatalf("unexpected manifest: %#v", m)
+	}
+}
+
+func TestLoadManifestYAMLReportsPositionAndKey(t *testing.T) {
+	content := "apiVersion: v1\nprofiles:\n  custom:\n    tols: [go]\n"
+	path := filepath.Join(t.TempDir(), "prepare.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	_, err := LoadManifest(path)
+	var manifestErr *ManifestError
+	if !errors.As(err, &manifestErr) {
+		t.Fatalf("expected ManifestError, got %v", err)
+	}
+	if manifestErr.Line != 4 || manifestErr.Column != 5 || manifestErr.Key != "profiles.custom.tols" {
+		t.Fatalf("unexpected error position: %#v", manifestErr)
+	}
+}
+
+func TestLoadManifestJSONRejectsUnknownKeys(t *testing.T) {
+	tests := map[string]string{
+		"tols":                   `{"apiVersion": "v1", "tols": ["go"]}`,
+		"profiles.custom.exlude": "{\n  \"apiVersion\": \"v1\",\n  \"profiles\": {\"custom\": {\"exlude\": [\"go\"]}}\n}",
+		"tools.acme.check.binry": `{"apiVersion": "v1", "tools": {"acme": {"install": {"name": "acme"}, "check": {"binry": "acme"}}}}`,
+	}
+	for key, content := range tests {
+		path := filepath.Join(t.TempDir(), "prepare.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write manifest: %v", err)
+		}
+		_, err := LoadManifest(path)
+		var manifestErr *ManifestError
+		if !errors.As(err, &manifestErr) || manifestErr.Key != key || manifestErr.Line == 0 {
+			t.Fatalf("%s: expected positioned unknown key error, got %v", key, err)
+		}
+		if d := DiagnosticFromError(path, err); d.Code != RuleSyntax || d.Line != manifestErr.Line {
+			t.Fatalf("%s: unexpected diagnostic %#v", key, d)
+		}
+	}
+}
+
+func TestLoadManifestToolDefinitionsYAMLAndJSON(t *testing.T) {
+	yamlContent := `apiVersion: v1
+tools:
+  acme-cli:
+    title: Acme CLI
+    dependencies: [go]
+    install:
+      name: go
+      args: [install, example.com/acme@latest]
+    check:
+      binary: acme
+    version: "1.4.0"
+`
+	jsonContent := `{
+  "apiVersion": "v1",
+  "tools": {
+    "acme-cli": {
+      "title": "Acme CLI",
+      "dependencies": ["go"],
+      "install": {"name": "go", "args": ["install", "example.com/acme@latest"]},
+      "check": {"binary": "acme"},
+      "version": "1.4.0"
+    }
+  }
+}`
+	dir := t.TempDir()
+	for name, content := range map[string]string{"prepare.yaml": yamlContent, "prepare.json": jsonContent} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("write manifest: %v", err)
+			}
+			m, err := LoadManifest(path)
+			if err != nil {
+				t.Fatalf("LoadManifest error: %v", err)
+			}
+			if len(m.Tools) != 0 {
+				t.Fatalf("expected definitions not to select tools, got %#v", m.Tools)
+			}
+			spec := m.ToolSpecs["acme-cli"]
+			if spec.ID != "acme-cli" || spec.Install.Name != "go" || spec.Check.Binary != "acme" || spec.Version != "1.4.0" {
+				t.Fatalf("unexpected tool spec: %#v", spec)
+			}
+		})
+	}
+}
+
+func TestLoadManifestRejectsEmptyToolDefinitions(t *testing.T) {
+	for name, content := range map[string]string{
+		"prepare.yaml": "apiVersion: v1\ntools: {not-real: ~}\n",
+		"prepare.json": "{\"apiVersion\": \"v1\",\n \"tools\": {\"not-real\": null}}",
+	} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write manifest: %v", err)
+		}
+		_, err := LoadManifest(path)
+		var manifestErr *ManifestError
+		if !errors.As(err, &manifestErr) || manifestErr.Key != "tools.not-real" || manifestErr.Line != 2 {
+			t.Fatalf("%s: expected positioned empty definition error, got %v", name, err)
+		}
+	}
+}
+
+func TestDefinedToolIsOnlyPlannedWhenSelected(t *testing.T) {
+	content := `apiVersion: v1
+profiles:
+  team:
+    extends: [frontend]
+    tools: [acme-cli]
+tools:
+  acme-cli:
+    title: Acme CLI
+    install: {name: go, args: [install, example.com/acme@latest]}
+    check: {binary: acme}
+`
+	path := filepath.Join(t.TempDir(), "prepare.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatalf("LoadManifest error: %v", err)
+	}
+	for profile, want := range map[string]bool{"frontend": false, "team": true} {
+		tools, err := ResolveTools(m, profile, BuiltinCatalog(), BuiltinProfiles())
+		if err != nil {
+			t.Fatalf("%s: ResolveTools error: %v", profile, err)
+		}
+		if got := slices.Contains(tools, "acme-cli"); got != want {
+			t.Fatalf("%s: expected acme-cli planned=%v, got tools %v", profile, want, tools)
+		}
+	}
+}
+
+func TestManifestToolsFlowIntoPlan(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "base",
+		Tools:      []string{"acme-cli"},
+		ToolSpecs: map[string]ToolSpec{
+			"acme-cli": {Dependencies: []string{"go"}, Install: Command{Name: "go"}, Check: Check{Binary: "acme"}},
+			"git":      {Version: "2.44.0"},
+		},
+	}
+	if err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles()); err != nil {
+		t.Fatalf("ValidateManifest error: %v", err)
+	}
+	tools, err := ResolveTools(m, "", BuiltinCatalog(), BuiltinProfiles())
+	if err != nil {
+		t.Fatalf("ResolveTools error: %v", err)
+	}
+	catalog := MergeCatalog(BuiltinCatalog(), m.ToolSpecs)
+	plan, err := BuildPlan(tools, catalog)
+	if err != nil {
+		t.Fatalf("BuildPlan error: %v", err)
+	}
+	order := map[string]int{}
+	for _, step := range plan.Steps {
+		order[step.Tool.ID] = step.Order
+	}
+	if order["acme-cli"] <= order["go"] {
+		t.Fatalf("expected acme-cli after go in plan: %#v", order)
+	}
+	lock := BuildLockfile(plan)
+	for _, tool := range lock.Tools {
+		if tool.ID == "git" && tool.Version != "2.44.0" {
+			t.Fatalf("expected git override version in lockfile, got %q", tool.Version)
+		}
+	}
+	if catalog["git"].Install.Name != "brew" {
+		t.Fatalf("expected git override to keep builtin install, got %#v", catalog["git"].Install)
+	}
+}
+
+func TestValidateManifestRejectsIncompleteToolDefinition(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		ToolSpecs:  map[string]ToolSpec{"acme-cli": {Check: Check{Binary: "acme"}}},
+	}
+	err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles())
+	if err == nil || !strings.Contains(err.Error(), "no install command") {
+		t.Fatalf("expected missing install error, got %v", err)
+	}
+}
+
+func TestValidateManifestRejectsInvalidRetryPolicy(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		ToolSpecs:  map[string]ToolSpec{"docker": {Retry: &RetryPolicy{Retries: 2, Backoff: "linear"}, Timeout: "10m"}},
+	}
+	err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles())
+	if err == nil || !strings.Contains(err.Error(), `unknown retry backoff "linear"`) {
+		t.Fatalf("expected invalid backoff error, got %v", err)
+	}
+}
+
+func TestResolveToolsProfileExcludeDropsInheritedTools(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "lean",
+		Profiles: map[string]Profile{
+			"lean": {Extends: []string{"fullstack"}, Exclude: []string{"iterm2", "dotnet"}},
+		},
+	}
+	tools, err := ResolveTools(m, "", BuiltinCatalog(), BuiltinProfiles())
+	if err != nil {
+		t.Fatalf("ResolveTools error: %v", err)
+	}
+	for _, tool := range tools {
+		if tool == "iterm2" || tool == "dotnet" {
+			t.Fatalf("expected %q to be excluded from %#v", tool, tools)
+		}
+	}
+	if len(tools) == 0 {
+		t.Fatal("expected remaining fullstack tools")
+	}
+}
+
+func TestResolveToolsRejectsExcludedDependency(t *testing.T) {
+	m := Manifest{
+		APIVersion: "v1",
+		Profile:    "nobrew",
+		Profiles: map[string]Profile{
+			"nobrew": {Extends: []string{"backend"}, Exclude: []string{"homebrew"}},
+		},
+	}
+	_, err := ResolveTools(m, "", BuiltinCatalog(), BuiltinProfiles())
+	if err == nil || !strings.Contains(err.Error(), `excludes tool "homebrew"`) {
+		t.Fatalf("expected excluded dependency error, got %v", err)
+	}
+	if err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles()); err == nil {
+		t.Fatal("expected lint to reject excluded dependency")
+	}
+}
+
+func TestLoadManifestVersionConstraintShorthand(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"prepare.yaml": "apiVersion: v1\ntools:\n  go: \">=1.22 <1.24\"\n",
+		"prepare.json": `{"apiVersion": "v1", "tools": {"go": ">=1.22 <1.24"}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write manifest: %v", err)
+		}
+		m, err := LoadManifest(path)
+		if err != nil {
+			t.Fatalf("%s: LoadManifest error: %v", name, err)
+		}
+		if got := m.ToolSpecs["go"].Constraint; got != ">=1.22 <1.24" {
+			t.Fatalf("%s: unexpected constraint %q", name, got)
+		}
+		if len(m.Tools) != 0 {
+			t.Fatalf("%s: expected a constraint not to select the tool, got %#v", name, m.Tools)
+		}
+		tools, err := ResolveTools(m, "frontend", BuiltinCatalog(), BuiltinProfiles())
+		if err != nil || slices.Contains(tools, "go") {
+			t.Fatalf("%s: expected go to stay out of the frontend plan, got %v (err %v)", name, tools, err)
+		}
+		if err := ValidateManifest(m, BuiltinCatalog(), BuiltinProfiles()); err != nil {
+			t.Fatalf("%s: ValidateManifest error: %v", name, err)
+		}
+		if catalog := MergeCatalog(BuiltinCatalog(), m.ToolSpecs); catalog["go"].Install.Name != "brew" {
+			t.Fatalf("%s: expected constraint to keep builtin go definition", name)
+		}
+	}
+}
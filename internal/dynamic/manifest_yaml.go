@@ -0,0 +1,443 @@
+package dynamic
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestError reports a manifest problem at a position in the source file.
+type ManifestError struct {
+	Line   int
+	Column int
+	Key    string
+	Msg    string
+}
+
+func (e *ManifestError) Error() string {
+	pos := ""
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		pos = fmt.Sprintf("line %d, column %d: ", e.Line, e.Column)
+	case e.Line > 0:
+		pos = fmt.Sprintf("line %d: ", e.Line)
+	}
+	if e.Key != "" {
+		return fmt.Sprintf("%skey %q: %s", pos, e.Key, e.Msg)
+	}
+	return pos + e.Msg
+}
+
+var yamlLineErrorPattern = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+type yamlPair struct {
+	Path  string
+	Key   *yaml.Node
+	Value *yaml.Node
+}
+
+func parseYAMLManifest(content []byte) (Manifest, error) {
+	m := Manifest{APIVersion: "v1", Profiles: map[string]Profile{}}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return Manifest{}, fmt.Errorf("invalid YAML manifest: %w", yamlSyntaxError(err))
+	}
+	if len(doc.Content) == 0 {
+		return m, nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if isNullNode(root) {
+		return m, nil
+	}
+
+	pairs, err := mappingPairs("", root)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for _, p := range pairs {
+		switch p.Key.Value {
+		case "apiVersion":
+			if m.APIVersion, err = decodeYAMLString(p.Path, p.Value); err != nil {
+				return Manifest{}, err
+			}
+			if m.APIVersion == "" {
+				m.APIVersion = "v1"
+			}
+		case "profile":
+			if m.Profile, err = decodeYAMLString(p.Path, p.Value); err != nil {
+				return Manifest{}, err
+			}
+		case "tools":
+			if err := decodeYAMLManifestTools(&m, p.Path, p.Value); err != nil {
+				return Manifest{}, err
+			}
+		case "profiles":
+			if m.Profiles, err = decodeYAMLProfiles(p.Path, p.Value); err != nil {
+				return Manifest{}, err
+			}
+		default:
+			return Manifest{}, unknownKeyError(p)
+		}
+	}
+	return m, nil
+}
+
+// yamlPositions maps the key paths of a YAML (or JSON) document, such as
+// "profiles.custom.tools[1]", to their position in the source. It is best
+// effort and returns nil when content does not parse.
+func yamlPositions(content []byte) map[string]Position {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	positions := map[string]Position{}
+	collectYAMLPositions("", doc.Content[0], positions)
+	return positions
+}
+
+func collectYAMLPositions(path string, node *yaml.Node, positions map[string]Position) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode || key.Value == "<<" {
+				continue
+			}
+			child := joinYAMLPath(path, key.Value)
+			positions[child] = Position{Line: key.Line, Column: key.Column}
+			collectYAMLPositions(child, node.Content[i+1], positions)
+		}
+	case yaml.SequenceNode:
+		for idx, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, idx)
+			positions[child] = Position{Line: item.Line, Column: item.Column}
+			collectYAMLPositions(child, item, positions)
+		}
+	}
+}
+
+// decodeYAMLDocument decodes a single YAML document into v, reporting the same
+// positioned errors as manifest parsing.
+func decodeYAMLDocument(content []byte, v any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("invalid YAML: %w", yamlSyntaxError(err))
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return decodeYAMLValue("", doc.Content[0], reflect.ValueOf(v).Elem())
+}
+
+// decodeYAMLManifestTools accepts either a list of tool IDs, which selects
+// them, or a map of tool IDs to ToolSpec definitions merged over the catalog,
+// where a plain string value is shorthand for a version constraint. Defining
+// a tool does not select it.
+func decodeYAMLManifestTools(m *Manifest, path string, node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		tools, err := decodeYAMLStringList(path, node)
+		if err != nil {
+			return err
+		}
+		m.Tools = tools
+		return nil
+	}
+	pairs, err := mappingPairs(path, node)
+	if err != nil {
+		return err
+	}
+	for _, p := range pairs {
+		if isNullNode(p.Value) {
+			return &ManifestError{Line: p.Key.Line, Column: p.Key.Column, Key: p.Path, Msg: emptyToolDefinition}
+		}
+		if p.Value.Kind == yaml.ScalarNode {
+			m.addToolSpec(p.Key.Value, ToolSpec{Constraint: p.Value.Value})
+			continue
+		}
+		var spec ToolSpec
+		if err := decodeYAMLValue(p.Path, p.Value, reflect.ValueOf(&spec).Elem()); err != nil {
+			return err
+		}
+		m.addToolSpec(p.Key.Value, spec)
+	}
+	return nil
+}
+
+// decodeYAMLValue decodes node into v using the same field names as the JSON
+// encoding, so YAML and JSON manifests share one schema.
+func decodeYAMLValue(path string, node *yaml.Node, v reflect.Value) error {
+	node = resolveAlias(node)
+	if isNullNode(node) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeYAMLValue(path, node, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		pairs, err := mappingPairs(path, node)
+		if err != nil {
+			return err
+		}
+		fields := jsonFieldIndex(v.Type())
+		for _, p := range pairs {
+			idx, ok := fields[p.Key.Value]
+			if !ok {
+				return unknownKeyError(p)
+			}
+			if err := decodeYAMLValue(p.Path, p.Value, v.Field(idx)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nodeError(path, node, "expected a list")
+		}
+		out := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+		for idx, item := range node.Content {
+			if err := decodeYAMLValue(fmt.Sprintf("%s[%d]", path, idx), item, out.Index(idx)); err != nil {
+				return err
+			}
+		}
+		v.Set(out)
+		return nil
+	case reflect.Map:
+		pairs, err := mappingPairs(path, node)
+		if err != nil {
+			return err
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(pairs))
+		for _, p := range pairs {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeYAMLValue(p.Path, p.Value, elem); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(p.Key.Value).Convert(v.Type().Key()), elem)
+		}
+		v.Set(out)
+		return nil
+	default:
+		if node.Kind != yaml.ScalarNode {
+			return nodeError(path, node, "expected a scalar value")
+		}
+		if err := node.Decode(v.Addr().Interface()); err != nil {
+			return nodeError(path, node, fmt.Sprintf("invalid %s value %q", v.Kind(), node.Value))
+		}
+		return nil
+	}
+}
+
+func jsonFieldIndex(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+func decodeYAMLProfiles(path string, node *yaml.Node) (map[string]Profile, error) {
+	profiles := map[string]Profile{}
+	if isNullNode(node) {
+		return profiles, nil
+	}
+	pairs, err := mappingPairs(path, node)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pairs {
+		profile, err := decodeYAMLProfile(p.Path, p.Value)
+		if err != nil {
+			return nil, err
+		}
+		profiles[p.Key.Value] = profile
+	}
+	return profiles, nil
+}
+
+func decodeYAMLProfile(path string, node *yaml.Node) (Profile, error) {
+	var profile Profile
+	if isNullNode(node) {
+		return profile, nil
+	}
+	pairs, err := mappingPairs(path, node)
+	if err != nil {
+		return Profile{}, err
+	}
+	for _, p := range pairs {
+		switch p.Key.Value {
+		case "extends":
+			profile.Extends, err = decodeYAMLStringList(p.Path, p.Value)
+		case "tools":
+			profile.Tools, err = decodeYAMLStringList(p.Path, p.Value)
+		case "exclude":
+			profile.Exclude, err = decodeYAMLStringList(p.Path, p.Value)
+		default:
+			return Profile{}, unknownKeyError(p)
+		}
+		if err != nil {
+			return Profile{}, err
+		}
+	}
+	return profile, nil
+}
+
+// mappingPairs flattens a mapping node into its key/value pairs, applying
+// YAML merge keys ("<<") with lower precedence than keys written explicitly.
+func mappingPairs(path string, node *yaml.Node) ([]yamlPair, error) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return nil, nodeError(path, node, "expected a mapping")
+	}
+
+	explicit := []yamlPair{}
+	merged := []yamlPair{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		value := resolveAlias(node.Content[i+1])
+		if key.Kind == yaml.ScalarNode && key.Value == "<<" && key.ShortTag() == "!!merge" {
+			pairs, err := mergePairs(path, value)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, pairs...)
+			continue
+		}
+		if key.Kind != yaml.ScalarNode || isNullNode(key) {
+			return nil, nodeError(path, key, "mapping keys must be strings")
+		}
+		explicit = append(explicit, yamlPair{Path: joinYAMLPath(path, key.Value), Key: key, Value: value})
+	}
+
+	seen := map[string]bool{}
+	out := make([]yamlPair, 0, len(explicit)+len(merged))
+	for _, p := range explicit {
+		if seen[p.Key.Value] {
+			return nil, nodeError(p.Path, p.Key, "duplicate key")
+		}
+		seen[p.Key.Value] = true
+		out = append(out, p)
+	}
+	for _, p := range merged {
+		if seen[p.Key.Value] {
+			continue
+		}
+		seen[p.Key.Value] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func mergePairs(path string, node *yaml.Node) ([]yamlPair, error) {
+	if node.Kind != yaml.SequenceNode {
+		return mappingPairs(path, node)
+	}
+	// Earlier mappings in a merge sequence take precedence over later ones.
+	out := []yamlPair{}
+	seen := map[string]bool{}
+	for _, item := range node.Content {
+		pairs, err := mappingPairs(path, item)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pairs {
+			if seen[p.Key.Value] {
+				continue
+			}
+			seen[p.Key.Value] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func decodeYAMLString(path string, node *yaml.Node) (string, error) {
+	node = resolveAlias(node)
+	if isNullNode(node) {
+		return "", nil
+	}
+	if node.Kind != yaml.ScalarNode {
+		return "", nodeError(path, node, "expected a string")
+	}
+	return node.Value, nil
+}
+
+func decodeYAMLStringList(path string, node *yaml.Node) ([]string, error) {
+	node = resolveAlias(node)
+	if isNullNode(node) {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, nodeError(path, node, "expected a list")
+	}
+	out := make([]string, 0, len(node.Content))
+	for idx, item := range node.Content {
+		item = resolveAlias(item)
+		itemPath := fmt.Sprintf("%s[%d]", path, idx)
+		if item.Kind != yaml.ScalarNode || isNullNode(item) || item.Value == "" {
+			return nil, nodeError(itemPath, item, "expected a non-empty string")
+		}
+		out = append(out, item.Value)
+	}
+	return out, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func isNullNode(node *yaml.Node) bool {
+	return node == nil || (node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null")
+}
+
+func joinYAMLPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func nodeError(path string, node *yaml.Node, msg string) error {
+	return &ManifestError{Line: node.Line, Column: node.Column, Key: path, Msg: msg}
+}
+
+func unknownKeyError(p yamlPair) error {
+	return &ManifestError{Line: p.Key.Line, Column: p.Key.Column, Key: p.Path, Msg: "unknown key"}
+}
+
+func yamlSyntaxError(err error) error {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) && len(typeErr.Errors) > 0 {
+		err = errors.New("yaml: " + typeErr.Errors[0])
+	}
+	match := yamlLineErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(match[1])
+	return &ManifestError{Line: line, Msg: match[2]}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
 )
 
 func PrintJSON(v any) error {
@@ -29,13 +31,142 @@ func PrintExecutionHuman(result ExecutionResult) {
 		if step.Action == "skip" {
 			status = "skipped"
 		}
-		fmt.Printf("- %s: %s", step.ToolID, status)
+		fmt.Printf("- %s: %s", stepLabel(step), status)
 		if step.Reason != "" {
 			fmt.Printf(" (%s)", step.Reason)
 		}
+		if step.PreviousVersion != "" && step.PreviousVersion != step.Version {
+			fmt.Printf(" previous=%s", step.PreviousVersion)
+		}
+		if step.Version != "" {
+			fmt.Printf(" version=%s", step.Version)
+		}
+		if step.Attempts > 1 {
+			fmt.Printf(" attempts=%d", step.Attempts)
+		}
+		if step.TimedOut {
+			fmt.Printf(" timed-out")
+		}
+		if step.FailedDependency != "" {
+			fmt.Printf(" dependency=%s", step.FailedDependency)
+		}
 		if step.Error != "" {
 			fmt.Printf(" error=%s", step.Error)
 		}
 		fmt.Printf("\n")
 	}
+	printFailureSummary(result)
+	if len(result.RolledBack) > 0 {
+		fmt.Printf("Rollback (%d steps):\n", len(result.RolledBack))
+	}
+	for _, step := range result.RolledBack {
+		status := "rolled back"
+		if !step.Success {
+			status = "rollback failed: " + step.Error
+		}
+		fmt.Printf("- %s: %s\n", step.ToolID, status)
+	}
+}
+
+func stepLabel(step ExecutionStep) string {
+	if step.Action == ActionPreInstall || step.Action == ActionPostInstall {
+		return step.ToolID + " " + step.Action
+	}
+	return step.ToolID
+}
+
+func printFailureSummary(result ExecutionResult) {
+	skippedBy := map[string][]string{}
+	for _, step := range result.Steps {
+		if step.FailedDependency != "" {
+			skippedBy[step.FailedDependency] = append(skippedBy[step.FailedDependency], step.ToolID)
+		}
+	}
+	for _, step := range result.Steps {
+		if step.Success || step.Action == "skip" {
+			continue
+		}
+		fmt.Printf("Failed: %s", stepLabel(step))
+		if skipped := skippedBy[step.ToolID]; len(skipped) > 0 {
+			fmt.Printf(" (skipped dependents: %s)", strings.Join(skipped, ", "))
+		}
+		fmt.Printf("\n")
+		if step.LogPath != "" {
+			fmt.Printf("  log: %s\n", step.LogPath)
+		}
+		for _, line := range strings.Split(step.OutputTail, "\n") {
+			if line != "" {
+				fmt.Printf("  | %s\n", line)
+			}
+		}
+	}
+}
+
+func PrintDiagnosticsHuman(diagnostics []Diagnostic) {
+	errorCount, warningCount := 0, 0
+	for _, d := range diagnostics {
+		location := d.File
+		if location == "" {
+			location = "manifest"
+		}
+		if d.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, d.Line)
+			if d.Column > 0 {
+				location = fmt.Sprintf("%s:%d", location, d.Column)
+			}
+		}
+		fmt.Printf("%s: %s %s: %s\n", location, d.Severity, d.Code, d.Message)
+		if d.Severity == SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+	if len(diagnostics) == 0 {
+		fmt.Println("manifest is valid")
+		return
+	}
+	fmt.Printf("%d error(s), %d warning(s)\n", errorCount, warningCount)
+}
+
+func PrintVerifyHuman(report VerifyReport) {
+	for _, d := range report.Drift {
+		fmt.Printf("- %s: %s", d.ToolID, d.Kind)
+		if d.Expected != "" || d.Actual != "" {
+			fmt.Printf(" (expected %s, found %s)", orNone(d.Expected), orNone(d.Actual))
+		}
+		fmt.Printf("\n")
+	}
+	if !report.HasDrift() {
+		fmt.Printf("%d tool(s) match the lockfile\n", report.Checked)
+		return
+	}
+	fmt.Printf("%d drift(s) across %d locked tool(s)\n", len(report.Drift), report.Checked)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func PrintToolListHuman(listings []ToolListing) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tINSTALLED\tVERSION\tSOURCE\tPROFILES")
+	for _, l := range listings {
+		installed := "no"
+		if l.Installed {
+			installed = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", l.ID, l.Title, installed, orDash(l.Version), orDash(l.Source), orDash(strings.Join(l.Profiles, ",")))
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
 }
@@ -0,0 +1,46 @@
+package dynamic
+
+import "felipewom/go-env-prepare/internal/progress"
+
+// NewProgressObserver drives r from run events: one progress step per tool,
+// which fails if any of the tool's steps fails.
+func NewProgressObserver(r *progress.Renderer) Observer {
+	failed := map[string]bool{}
+	return ObserverFunc(func(ev Event) {
+		switch ev.Type {
+		case EventRunStarted:
+			for _, id := range ev.Tools {
+				r.Add(id, id)
+			}
+		case EventStepStarted:
+			r.Start(ev.ToolID)
+		case EventOutput:
+			r.Output(ev.ToolID, ev.Line)
+		case EventStepFinished:
+			status, detail := progressStatus(*ev.Step)
+			if status == progress.StatusFailed {
+				failed[ev.ToolID] = true
+			} else if failed[ev.ToolID] && ev.Step.Action != "rollback" {
+				return
+			}
+			r.Finish(ev.ToolID, status, detail)
+		}
+	})
+}
+
+func progressStatus(step ExecutionStep) (progress.Status, string) {
+	switch {
+	case !step.Success && step.Action != "skip":
+		return progress.StatusFailed, step.Error
+	case step.Action == "rollback":
+		return progress.StatusSkipped, "rolled back"
+	case step.Action == "skip":
+		if step.FailedDependency != "" {
+			return progress.StatusSkipped, "skipped, " + step.FailedDependency + " failed"
+		}
+		return progress.StatusSkipped, step.Reason
+	case step.Reason == ReasonDryRun:
+		return progress.StatusDone, ReasonDryRun
+	}
+	return progress.StatusDone, step.Version
+}
@@ -0,0 +1,73 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	BackoffFixed       = "fixed"
+	BackoffExponential = "exponential"
+
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 5 * time.Minute
+)
+
+func (p RetryPolicy) validate() error {
+	if p.Retries < 0 {
+		return fmt.Errorf("retry count must not be negative")
+	}
+	if p.Delay != "" {
+		if _, err := time.ParseDuration(p.Delay); err != nil {
+			return fmt.Errorf("invalid retry delay %q", p.Delay)
+		}
+	}
+	switch p.Backoff {
+	case "", BackoffFixed, BackoffExponential:
+	default:
+		return fmt.Errorf("unknown retry backoff %q (want %s or %s)", p.Backoff, BackoffFixed, BackoffExponential)
+	}
+	return nil
+}
+
+// delayBefore returns the wait before retry n (starting at 1). Exponential
+// backoff, the default, doubles the delay after every retry up to
+// maxRetryDelay.
+func (p RetryPolicy) delayBefore(n int) time.Duration {
+	delay := defaultRetryDelay
+	if d, err := time.ParseDuration(p.Delay); err == nil {
+		delay = d
+	}
+	if p.Backoff == BackoffFixed || delay <= 0 {
+		return delay
+	}
+	backoff := delay
+	for i := 1; i < n && backoff < maxRetryDelay; i++ {
+		backoff *= 2
+	}
+	// A configured delay above the cap is kept as is rather than shortened.
+	return min(backoff, max(delay, maxRetryDelay))
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return d, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package dynamic
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+type SARIFConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations,omitempty"`
+}
+
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion          `json:"region,omitempty"`
+}
+
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type SARIFRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// BuildSARIF converts lint diagnostics into a SARIF 2.1.0 log that code
+// scanning services can use to annotate pull requests.
+func BuildSARIF(diagnostics []Diagnostic) SARIFLog {
+	rules := []SARIFRule{}
+	for _, rule := range LintRules() {
+		rules = append(rules, SARIFRule{
+			ID:                   rule.Code,
+			Name:                 rule.Name,
+			ShortDescription:     SARIFMessage{Text: rule.Description},
+			DefaultConfiguration: SARIFConfiguration{Level: string(rule.Severity)},
+		})
+	}
+
+	results := make([]SARIFResult, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		result := SARIFResult{RuleID: d.Code, Level: string(d.Severity), Message: SARIFMessage{Text: d.Message}}
+		if d.File != "" {
+			location := SARIFLocation{PhysicalLocation: SARIFPhysicalLocation{ArtifactLocation: SARIFArtifactLocation{URI: d.File}}}
+			if d.Line > 0 {
+				location.PhysicalLocation.Region = &SARIFRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			result.Locations = []SARIFLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	return SARIFLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []SARIFRun{{
+			Tool: SARIFTool{Driver: SARIFDriver{
+				Name:           "go-env-prepare",
+				InformationURI: "https://github.com/felipewom/go-env-prepare",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
@@ -3,31 +3,126 @@ package dynamic
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 )
 
+// StateVersion is the state file format written by SaveState. Version 1
+// files, which only recorded a completed map, are migrated on load.
+const StateVersion = 2
+
+var (
+	ErrStateLocked       = errors.New("state file is in use by another prepare run")
+	ErrStatePlanMismatch = errors.New("state file was written for a different plan")
+)
+
+func NewState() State {
+	return State{Version: StateVersion, Tools: map[string]ToolState{}}
+}
+
 func LoadState(path string) (State, error) {
 	b, err := os.ReadFile(path)
 	if errors.Is(err, os.ErrNotExist) {
-		return State{Completed: map[string]bool{}}, nil
+		return NewState(), nil
 	}
 	if err != nil {
 		return State{}, err
 	}
-	var s State
-	if err := json.Unmarshal(b, &s); err != nil {
+	var raw struct {
+		State
+		Completed map[string]bool `json:"completed"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
 		return State{}, err
 	}
-	if s.Completed == nil {
-		s.Completed = map[string]bool{}
+	s := raw.State
+	switch s.Version {
+	case 0, 1:
+		s.Tools = make(map[string]ToolState, len(raw.Completed))
+		for id, done := range raw.Completed {
+			if done {
+				s.Tools[id] = ToolState{Completed: true}
+			}
+		}
+		s.Version = StateVersion
+	case StateVersion:
+	default:
+		return State{}, fmt.Errorf("unsupported state version %d", s.Version)
+	}
+	if s.Tools == nil {
+		s.Tools = map[string]ToolState{}
 	}
 	return s, nil
 }
 
 func SaveState(path string, state State) error {
+	state.Version = StateVersion
 	b, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, b, 0o644)
+	return writeFileAtomic(path, b, 0o644)
+}
+
+// LockState takes an advisory lock on path+".lock" so that two prepare runs
+// never write the same state file. It fails with ErrStateLocked instead of
+// waiting; the returned function releases the lock.
+func LockState(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("%w: %s", ErrStateLocked, path)
+		}
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes to a temporary file in the same directory and
+// renames it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, b []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// updateStateFile applies update to the state file at path under the state
+// lock, keeping whatever other runs recorded in it.
+func updateStateFile(path string, update func(*State)) error {
+	unlock, err := LockState(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := LoadState(path)
+	if err != nil {
+		return err
+	}
+	update(&state)
+	return SaveState(path, state)
 }
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package dynamic
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockHeld = errors.New("lock held")
+
+// Advisory locks are only implemented on unix; elsewhere the lock file is
+// created but not locked.
+func tryLockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,74 @@
+package dynamic
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadStateMigratesV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"completed":{"git":true,"go":false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState error: %v", err)
+	}
+	if state.Version != StateVersion || !state.Tools["git"].Completed || len(state.Tools) != 1 {
+		t.Fatalf("unexpected migrated state: %#v", state)
+	}
+	if err := SaveState(path, state); err != nil {
+		t.Fatalf("SaveState error: %v", err)
+	}
+	if reloaded, err := LoadState(path); err != nil || !reloaded.Tools["git"].Completed {
+		t.Fatalf("unexpected reloaded state: %#v (err %v)", reloaded, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"version":3,"tools":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Fatal("expected unsupported version error")
+	}
+}
+
+func TestResumeRejectsDifferentPlan(t *testing.T) {
+	executor := newTestExecutor(map[string]string{"git": "2.44.0"})
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	plan := Plan{Steps: []PlanStep{{Tool: ToolSpec{ID: "git", Install: Command{Name: "git"}, Check: Check{Binary: "git"}}}}}
+	if _, err := executor.Run(plan, ExecOptions{Resume: true, StatePath: statePath}); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	changed := Plan{Steps: []PlanStep{{Tool: ToolSpec{ID: "git", Install: Command{Name: "git", Args: []string{"--new"}}, Check: Check{Binary: "git"}}}}}
+	if _, err := executor.Run(changed, ExecOptions{Resume: true, StatePath: statePath}); !errors.Is(err, ErrStatePlanMismatch) {
+		t.Fatalf("expected plan mismatch, got %v", err)
+	}
+	result, err := executor.Run(changed, ExecOptions{Resume: true, StatePath: statePath, Force: true})
+	if err != nil || result.Steps[0].Reason != ReasonAlreadyCompleted {
+		t.Fatalf("expected forced resume to reuse state, got %#v (err %v)", result.Steps, err)
+	}
+}
+
+func TestLockStateRejectsSecondHolder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("advisory locks are unix only")
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	unlock, err := LockState(path)
+	if err != nil {
+		t.Fatalf("LockState error: %v", err)
+	}
+	if _, err := LockState(path); !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("expected ErrStateLocked, got %v", err)
+	}
+	unlock()
+	unlock, err = LockState(path)
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	unlock()
+}
@@ -3,37 +3,92 @@ package dynamic
 import "time"
 
 type Manifest struct {
-	APIVersion string             `json:"apiVersion"`
-	Profile    string             `json:"profile,omitempty"`
-	Tools      []string           `json:"tools,omitempty"`
-	Profiles   map[string]Profile `json:"profiles,omitempty"`
+	APIVersion string              `json:"apiVersion"`
+	Profile    string              `json:"profile,omitempty"`
+	Tools      []string            `json:"tools,omitempty"`
+	Profiles   map[string]Profile  `json:"profiles,omitempty"`
+	ToolSpecs  map[string]ToolSpec `json:"-"`
+
+	positions map[string]Position
+	digest    string
+}
+
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
 }
 
 type Profile struct {
 	Extends []string `json:"extends,omitempty"`
 	Tools   []string `json:"tools,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 type ToolSpec struct {
-	ID           string   `json:"id"`
-	Title        string   `json:"title"`
-	Description  string   `json:"description"`
-	Dependencies []string `json:"dependencies,omitempty"`
-	Install      Command  `json:"install"`
-	Check        Check    `json:"check"`
-	Version      string   `json:"version,omitempty"`
-	Source       string   `json:"source,omitempty"`
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Description   string       `json:"description"`
+	Dependencies  []string     `json:"dependencies,omitempty"`
+	Install       Command      `json:"install"`
+	PinnedInstall *Command     `json:"pinnedInstall,omitempty"`
+	Uninstall     *Command     `json:"uninstall,omitempty"`
+	Upgrade       *Command     `json:"upgrade,omitempty"`
+	PreInstall    []Command    `json:"preInstall,omitempty"`
+	PostInstall   []Command    `json:"postInstall,omitempty"`
+	Check         Check        `json:"check"`
+	Version       string       `json:"version,omitempty"`
+	Constraint    string       `json:"constraint,omitempty"`
+	Source        string       `json:"source,omitempty"`
+	Lock          string       `json:"lock,omitempty"`
+	Retry         *RetryPolicy `json:"retry,omitempty"`
+	Timeout       string       `json:"timeout,omitempty"`
+}
+
+type RetryPolicy struct {
+	Retries int    `json:"retries"`
+	Delay   string `json:"delay,omitempty"`
+	Backoff string `json:"backoff,omitempty"`
 }
 
 type Command struct {
-	Name  string   `json:"name"`
-	Args  []string `json:"args,omitempty"`
-	Shell string   `json:"shell,omitempty"`
+	Name  string            `json:"name"`
+	Args  []string          `json:"args,omitempty"`
+	Shell string            `json:"shell,omitempty"`
+	Env   map[string]string `json:"env,omitempty"`
+	Dir   string            `json:"dir,omitempty"`
+	Stdin string            `json:"stdin,omitempty"`
 }
 
+// Check decides whether a tool is installed. The leaf conditions set on one
+// Check are OR'ed, as Binary and PathExists always were; All, Any and Not
+// are AND'ed with that result. Version only probes the installed version.
 type Check struct {
-	Binary     string `json:"binary,omitempty"`
-	PathExists string `json:"pathExists,omitempty"`
+	Binary        string        `json:"binary,omitempty"`
+	PathExists    string        `json:"pathExists,omitempty"`
+	EnvSet        string        `json:"envSet,omitempty"`
+	FileContains  *FileContains `json:"fileContains,omitempty"`
+	Command       *Command      `json:"command,omitempty"`
+	OutputMatches *OutputMatch  `json:"outputMatches,omitempty"`
+	DirNotEmpty   string        `json:"dirNotEmpty,omitempty"`
+	All           []Check       `json:"all,omitempty"`
+	Any           []Check       `json:"any,omitempty"`
+	Not           *Check        `json:"not,omitempty"`
+	Version       *VersionProbe `json:"version,omitempty"`
+}
+
+type FileContains struct {
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+type OutputMatch struct {
+	Command Command `json:"command"`
+	Pattern string  `json:"pattern"`
+}
+
+type VersionProbe struct {
+	Command Command `json:"command"`
+	Pattern string  `json:"pattern"`
 }
 
 type Plan struct {
@@ -47,33 +102,81 @@ type PlanStep struct {
 }
 
 type ExecutionResult struct {
-	StartedAt time.Time       `json:"startedAt"`
-	EndedAt   time.Time       `json:"endedAt"`
-	DryRun    bool            `json:"dryRun"`
-	Steps     []ExecutionStep `json:"steps"`
+	StartedAt  time.Time       `json:"startedAt"`
+	EndedAt    time.Time       `json:"endedAt"`
+	DryRun     bool            `json:"dryRun"`
+	Steps      []ExecutionStep `json:"steps"`
+	RolledBack []ExecutionStep `json:"rolledBack,omitempty"`
+	RunID      string          `json:"runId,omitempty"`
+	LogDir     string          `json:"logDir,omitempty"`
 }
 
 type ExecutionStep struct {
-	ToolID     string `json:"toolId"`
-	Action     string `json:"action"`
-	Reason     string `json:"reason,omitempty"`
-	Success    bool   `json:"success"`
-	Error      string `json:"error,omitempty"`
-	DurationMs int64  `json:"durationMs"`
+	ToolID           string    `json:"toolId"`
+	Action           string    `json:"action"`
+	Reason           string    `json:"reason,omitempty"`
+	Success          bool      `json:"success"`
+	Error            string    `json:"error,omitempty"`
+	Version          string    `json:"version,omitempty"`
+	PreviousVersion  string    `json:"previousVersion,omitempty"`
+	FailedDependency string    `json:"failedDependency,omitempty"`
+	Attempts         int       `json:"attempts,omitempty"`
+	TimedOut         bool      `json:"timedOut,omitempty"`
+	LogPath          string    `json:"logPath,omitempty"`
+	OutputTail       string    `json:"outputTail,omitempty"`
+	StartedAt        time.Time `json:"startedAt"`
+	EndedAt          time.Time `json:"endedAt"`
+	DurationMs       int64     `json:"durationMs"`
 }
 
 type State struct {
-	Completed map[string]bool `json:"completed"`
+	Version  int                  `json:"version"`
+	PlanHash string               `json:"planHash,omitempty"`
+	Tools    map[string]ToolState `json:"tools"`
+	LastRun  *ExecutionResult     `json:"lastRun,omitempty"`
+}
+
+type ToolState struct {
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	// PendingHooks marks a tool that was installed but whose post-install
+	// hooks have not succeeded yet; the next run reruns them.
+	PendingHooks bool `json:"pendingHooks,omitempty"`
 }
 
 type Lockfile struct {
-	Version     int          `json:"version"`
-	GeneratedAt time.Time    `json:"generatedAt"`
-	Tools       []LockedTool `json:"tools"`
+	Version      int          `json:"version"`
+	GeneratedAt  time.Time    `json:"generatedAt"`
+	Platform     Platform     `json:"platform"`
+	ManifestHash string       `json:"manifestHash,omitempty"`
+	CatalogHash  string       `json:"catalogHash"`
+	Tools        []LockedTool `json:"tools"`
+}
+
+type Platform struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
 }
 
 type LockedTool struct {
-	ID      string `json:"id"`
-	Version string `json:"version,omitempty"`
-	Source  string `json:"source,omitempty"`
+	ID         string `json:"id"`
+	Version    string `json:"version,omitempty"`
+	Requested  string `json:"requested,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+	Source     string `json:"source,omitempty"`
+}
+
+type Drift struct {
+	ToolID   string `json:"toolId"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+type VerifyReport struct {
+	Lockfile string  `json:"lockfile,omitempty"`
+	Checked  int     `json:"checked"`
+	Drift    []Drift `json:"drift"`
 }
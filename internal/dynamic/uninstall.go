@@ -0,0 +1,124 @@
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type UninstallOptions struct {
+	DryRun bool
+	Force  bool
+	// StatePath, when set, is the state file removed tools are dropped from.
+	StatePath string
+	// Output, when set, receives the output of the uninstall commands.
+	Output io.Writer
+}
+
+// BuildUninstallPlan orders toolIDs so that every tool is removed before the
+// tools it depends on. Unlike BuildPlan it does not add dependencies.
+func BuildUninstallPlan(toolIDs []string, catalog map[string]ToolSpec) (Plan, error) {
+	plan, err := BuildPlan(toolIDs, catalog)
+	if err != nil {
+		return Plan{}, err
+	}
+	selected := map[string]bool{}
+	for _, id := range toolIDs {
+		selected[id] = true
+	}
+	steps := make([]PlanStep, 0, len(toolIDs))
+	for i := len(plan.Steps) - 1; i >= 0; i-- {
+		tool := plan.Steps[i].Tool
+		if !selected[tool.ID] {
+			continue
+		}
+		if tool.Uninstall == nil {
+			return Plan{}, fmt.Errorf("tool %q has no uninstall command", tool.ID)
+		}
+		steps = append(steps, PlanStep{Order: len(steps) + 1, Tool: tool})
+	}
+	return Plan{CreatedAt: plan.CreatedAt, Steps: steps}, nil
+}
+
+// Uninstall removes the planned tools in order, skipping tools that are not
+// installed, and drops each removed tool from the state file. Unless
+// opts.Force is set it refuses, before removing anything, when an installed
+// tool outside the plan depends on a planned one.
+func (e *Executor) Uninstall(ctx context.Context, plan Plan, catalog map[string]ToolSpec, opts UninstallOptions) (ExecutionResult, error) {
+	if err := e.preflight(); err != nil {
+		return ExecutionResult{}, err
+	}
+	if !opts.Force {
+		if err := e.checkInstalledDependents(plan, catalog); err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+
+	result := ExecutionResult{StartedAt: time.Now(), DryRun: opts.DryRun, Steps: []ExecutionStep{}}
+	for _, step := range plan.Steps {
+		tool := step.Tool
+		execStep := ExecutionStep{ToolID: tool.ID, Action: "uninstall", Success: true, StartedAt: time.Now()}
+		var err error
+		switch {
+		case !e.checkTool(tool.Check):
+			execStep.Action = "skip"
+			execStep.Reason = ReasonNotInstalled
+		case opts.DryRun:
+			execStep.Reason = ReasonDryRun
+		default:
+			var onLine func(string)
+			if opts.Output != nil {
+				onLine = prefixLines(opts.Output, tool.ID)
+			}
+			err = captureOutput("", tool.ID, &execStep, onLine, func(out io.Writer) error {
+				return e.withLock(lockFor(tool, *tool.Uninstall), func() error { return e.runToolCommand(ctx, tool, *tool.Uninstall, out) })
+			})
+		}
+		if err == nil && execStep.Action == "uninstall" && !opts.DryRun && opts.StatePath != "" {
+			if err = forgetTool(opts.StatePath, tool.ID); err != nil {
+				err = fmt.Errorf("update state: %w", err)
+			}
+		}
+		if err != nil {
+			execStep.Success = false
+			execStep.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, finishStep(execStep))
+		if err != nil {
+			result.EndedAt = time.Now()
+			return result, fmt.Errorf("uninstall %s: %w", tool.ID, err)
+		}
+	}
+	result.EndedAt = time.Now()
+	return result, nil
+}
+
+// forgetTool removes id from the state file at path under the state lock,
+// so a later --resume does not skip a tool that is no longer installed.
+func forgetTool(path string, id string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return updateStateFile(path, func(state *State) { delete(state.Tools, id) })
+}
+
+func (e *Executor) checkInstalledDependents(plan Plan, catalog map[string]ToolSpec) error {
+	removing := map[string]bool{}
+	for _, step := range plan.Steps {
+		removing[step.Tool.ID] = true
+	}
+	for _, id := range SortedToolIDs(catalog) {
+		if removing[id] {
+			continue
+		}
+		for _, dep := range catalog[id].Dependencies {
+			if removing[dep] && e.checkTool(catalog[id].Check) {
+				return fmt.Errorf("cannot uninstall %q: installed tool %q depends on it (use --force to remove anyway)", dep, id)
+			}
+		}
+	}
+	return nil
+}
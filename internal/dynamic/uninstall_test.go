@@ -0,0 +1,81 @@
+package dynamic
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinCatalogToolsCanBeUninstalled(t *testing.T) {
+	catalog := BuiltinCatalog()
+	plan, err := BuildUninstallPlan(SortedToolIDs(catalog), catalog)
+	if err != nil {
+		t.Fatalf("BuildUninstallPlan error: %v", err)
+	}
+	if last := plan.Steps[len(plan.Steps)-1].Tool.ID; last != "homebrew" {
+		t.Fatalf("expected homebrew to be removed last, got %q", last)
+	}
+}
+
+func TestUninstallReverseOrderAndInstalledDependents(t *testing.T) {
+	catalog := map[string]ToolSpec{
+		"homebrew": {ID: "homebrew", Check: Check{Binary: "brew"}, Uninstall: &Command{Name: "uninstall-brew"}},
+		"git":      {ID: "git", Dependencies: []string{"homebrew"}, Check: Check{Binary: "git"}, Uninstall: brewCommand("uninstall", "git")},
+		"go":       {ID: "go", Dependencies: []string{"homebrew"}, Check: Check{Binary: "go"}, Uninstall: brewCommand("uninstall", "go")},
+	}
+	executor := newTestExecutor(map[string]string{"brew": "4.2.0", "git": "2.44.0", "go": "1.22.1"})
+	removed := []string{}
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		removed = append(removed, cmd.Name+" "+strings.Join(cmd.Args, " "))
+		return nil
+	}
+
+	plan, err := BuildUninstallPlan([]string{"homebrew", "git"}, catalog)
+	if err != nil {
+		t.Fatalf("BuildUninstallPlan error: %v", err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Tool.ID != "git" || plan.Steps[1].Tool.ID != "homebrew" {
+		t.Fatalf("expected git before homebrew, got %#v", plan.Steps)
+	}
+
+	_, err = executor.Uninstall(context.Background(), plan, catalog, UninstallOptions{})
+	if err == nil || !strings.Contains(err.Error(), `installed tool "go" depends on it`) {
+		t.Fatalf("expected refusal because go depends on homebrew, got %v", err)
+	}
+	if len(removed) != 0 {
+		t.Fatalf("expected nothing removed after refusal, got %v", removed)
+	}
+
+	result, err := executor.Uninstall(context.Background(), plan, catalog, UninstallOptions{Force: true, DryRun: true})
+	if err != nil || len(removed) != 0 || result.Steps[0].Reason != ReasonDryRun {
+		t.Fatalf("expected forced dry-run to remove nothing, got %#v (err %v)", result.Steps, err)
+	}
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state := NewState()
+	for _, id := range []string{"homebrew", "git", "go"} {
+		state.Tools[id] = ToolState{Completed: true}
+	}
+	if err := SaveState(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+	result, err = executor.Uninstall(context.Background(), plan, catalog, UninstallOptions{Force: true, StatePath: statePath})
+	if err != nil {
+		t.Fatalf("Uninstall error: %v", err)
+	}
+	if len(removed) != 2 || removed[0] != "brew uninstall git" || removed[1] != "uninstall-brew " {
+		t.Fatalf("unexpected removals: %q", removed)
+	}
+	if result.Steps[0].Action != "uninstall" || !result.Steps[0].Success {
+		t.Fatalf("unexpected uninstall step: %#v", result.Steps[0])
+	}
+	state, err = LoadState(statePath)
+	if err != nil {
+		t.Fatalf("LoadState error: %v", err)
+	}
+	if _, ok := state.Tools["git"]; ok || len(state.Tools) != 1 || !state.Tools["go"].Completed {
+		t.Fatalf("expected only the removed tools dropped from state, got %#v", state.Tools)
+	}
+}
@@ -0,0 +1,94 @@
+package dynamic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const ReasonUpToDate = "up_to_date"
+
+type UpdateOptions struct {
+	DryRun bool
+	// Output, when set, receives the output of the upgrade commands.
+	Output io.Writer
+}
+
+// BuildUpdatePlan orders the installed tools among toolIDs so that every
+// tool is upgraded after the tools it depends on. Tools that are not
+// installed are left out; like BuildUninstallPlan it does not add
+// dependencies.
+func (e *Executor) BuildUpdatePlan(toolIDs []string, catalog map[string]ToolSpec) (Plan, error) {
+	plan, err := BuildPlan(toolIDs, catalog)
+	if err != nil {
+		return Plan{}, err
+	}
+	selected := map[string]bool{}
+	for _, id := range toolIDs {
+		selected[id] = true
+	}
+	steps := make([]PlanStep, 0, len(toolIDs))
+	for _, step := range plan.Steps {
+		tool := step.Tool
+		if !selected[tool.ID] || !e.checkTool(tool.Check) {
+			continue
+		}
+		if tool.Upgrade == nil {
+			return Plan{}, fmt.Errorf("tool %q has no upgrade command", tool.ID)
+		}
+		steps = append(steps, PlanStep{Order: len(steps) + 1, Tool: tool})
+	}
+	return Plan{CreatedAt: plan.CreatedAt, Steps: steps}, nil
+}
+
+// Update upgrades the planned tools in order, stopping at the first failure.
+// Each step records the version before the upgrade in PreviousVersion and
+// the version after it in Version.
+func (e *Executor) Update(ctx context.Context, plan Plan, opts UpdateOptions) (ExecutionResult, error) {
+	if err := e.preflight(); err != nil {
+		return ExecutionResult{}, err
+	}
+
+	result := ExecutionResult{StartedAt: time.Now(), DryRun: opts.DryRun, Steps: []ExecutionStep{}}
+	for _, step := range plan.Steps {
+		tool := step.Tool
+		execStep := ExecutionStep{ToolID: tool.ID, Action: "upgrade", Success: true, StartedAt: time.Now()}
+		status := e.inspect(tool)
+		execStep.PreviousVersion = status.Version
+		var err error
+		switch {
+		case status.Reason == ReasonNotInstalled:
+			execStep.Action = "skip"
+			execStep.Reason = ReasonNotInstalled
+		case opts.DryRun:
+			execStep.Reason = ReasonDryRun
+			execStep.Version = status.Version
+		default:
+			var onLine func(string)
+			if opts.Output != nil {
+				onLine = prefixLines(opts.Output, tool.ID)
+			}
+			err = captureOutput("", tool.ID, &execStep, onLine, func(out io.Writer) error {
+				return e.withLock(lockFor(tool, *tool.Upgrade), func() error { return e.runToolCommand(ctx, tool, *tool.Upgrade, out) })
+			})
+			if err == nil {
+				err = e.verifyVersion(tool, &execStep)
+			}
+			if err == nil && execStep.Version != "" && execStep.Version == execStep.PreviousVersion {
+				execStep.Reason = ReasonUpToDate
+			}
+		}
+		if err != nil {
+			execStep.Success = false
+			execStep.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, finishStep(execStep))
+		if err != nil {
+			result.EndedAt = time.Now()
+			return result, fmt.Errorf("upgrade %s: %w", tool.ID, err)
+		}
+	}
+	result.EndedAt = time.Now()
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package dynamic
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinCatalogToolsCanBeUpgraded(t *testing.T) {
+	catalog := BuiltinCatalog()
+	for _, id := range SortedToolIDs(catalog) {
+		if catalog[id].Upgrade == nil {
+			t.Fatalf("builtin tool %q has no upgrade command", id)
+		}
+	}
+	if args := strings.Join(catalog["vscode"].Upgrade.Args, " "); args != "upgrade --cask visual-studio-code" {
+		t.Fatalf("unexpected vscode upgrade: %q", args)
+	}
+}
+
+func TestUpdateUpgradesInstalledToolsInDependencyOrder(t *testing.T) {
+	probe := func(name string) Check {
+		return Check{Binary: name, Version: &VersionProbe{Command: Command{Name: name}, Pattern: `(.*)`}}
+	}
+	catalog := map[string]ToolSpec{
+		"homebrew": {ID: "homebrew", Check: probe("brew"), Upgrade: brewCommand("update")},
+		"git":      {ID: "git", Dependencies: []string{"homebrew"}, Check: probe("git"), Upgrade: brewCommand("upgrade", "git")},
+		"go":       {ID: "go", Dependencies: []string{"homebrew"}, Check: probe("go"), Upgrade: brewCommand("upgrade", "go")},
+	}
+	installed := map[string]string{"brew": "4.2.0", "git": "2.44.0"}
+	executor := newTestExecutor(installed)
+	upgraded := []string{}
+	executor.runCommand = func(ctx context.Context, cmd Command, out io.Writer) error {
+		upgraded = append(upgraded, strings.Join(cmd.Args, " "))
+		if cmd.Args[len(cmd.Args)-1] == "git" {
+			installed["git"] = "2.45.1"
+		}
+		return nil
+	}
+
+	plan, err := executor.BuildUpdatePlan([]string{"git", "go", "homebrew"}, catalog)
+	if err != nil {
+		t.Fatalf("BuildUpdatePlan error: %v", err)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Tool.ID != "homebrew" || plan.Steps[1].Tool.ID != "git" {
+		t.Fatalf("expected homebrew then git and no go, got %#v", plan.Steps)
+	}
+
+	result, err := executor.Update(context.Background(), plan, UpdateOptions{DryRun: true})
+	if err != nil || len(upgraded) != 0 || result.Steps[1].Reason != ReasonDryRun {
+		t.Fatalf("expected dry run to upgrade nothing, got %#v (err %v)", result.Steps, err)
+	}
+
+	result, err = executor.Update(context.Background(), plan, UpdateOptions{})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if strings.Join(upgraded, ",") != "update,upgrade git" {
+		t.Fatalf("unexpected upgrades: %q", upgraded)
+	}
+	brew, git := result.Steps[0], result.Steps[1]
+	if brew.Reason != ReasonUpToDate || brew.Version != "4.2.0" {
+		t.Fatalf("expected homebrew to be up to date, got %#v", brew)
+	}
+	if git.PreviousVersion != "2.44.0" || git.Version != "2.45.1" || git.Reason != "" {
+		t.Fatalf("expected git 2.44.0 -> 2.45.1, got %#v", git)
+	}
+
+	delete(catalog, "go")
+	catalog["git"] = ToolSpec{ID: "git", Check: probe("git")}
+	if _, err := executor.BuildUpdatePlan([]string{"git"}, catalog); err == nil || !strings.Contains(err.Error(), "no upgrade command") {
+		t.Fatalf("expected missing upgrade command error, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package dynamic
+
+const (
+	DriftMissing         = "missing"
+	DriftVersionMismatch = "version_mismatch"
+	DriftSourceMismatch  = "source_mismatch"
+	DriftUnknownTool     = "unknown_tool"
+)
+
+// Verify compares every locked tool with the machine: the catalog supplies
+// the check and version probe, and the source the tool would be installed
+// from today.
+func (e *Executor) Verify(lock Lockfile, catalog map[string]ToolSpec) VerifyReport {
+	report := VerifyReport{Drift: []Drift{}}
+	for _, locked := range lock.Tools {
+		report.Checked++
+		spec, ok := catalog[locked.ID]
+		if !ok {
+			report.Drift = append(report.Drift, Drift{ToolID: locked.ID, Kind: DriftUnknownTool})
+			continue
+		}
+		if locked.Source != "" && spec.Source != locked.Source {
+			report.Drift = append(report.Drift, Drift{ToolID: locked.ID, Kind: DriftSourceMismatch, Expected: locked.Source, Actual: spec.Source})
+		}
+		if !e.checkTool(spec.Check) {
+			report.Drift = append(report.Drift, Drift{ToolID: locked.ID, Kind: DriftMissing, Expected: locked.Version})
+			continue
+		}
+		if locked.Version == "" {
+			continue
+		}
+		actual := "unknown"
+		if spec.Check.Version != nil {
+			if v, err := e.probeVersion(*spec.Check.Version); err == nil {
+				actual = v
+			}
+		}
+		if !sameVersion(locked.Version, actual) {
+			report.Drift = append(report.Drift, Drift{ToolID: locked.ID, Kind: DriftVersionMismatch, Expected: locked.Version, Actual: actual})
+		}
+	}
+	return report
+}
+
+func (r VerifyReport) HasDrift() bool {
+	return len(r.Drift) > 0
+}
+
+// sameVersion treats "v1.2.3" and "1.2.3" as equal and falls back to a plain
+// comparison for versions that are not semver.
+func sameVersion(a, b string) bool {
+	va, errA := ParseVersion(a)
+	vb, errB := ParseVersion(b)
+	if errA == nil && errB == nil {
+		return va.Compare(vb) == 0
+	}
+	return a == b
+}
@@ -0,0 +1,39 @@
+package dynamic
+
+import "testing"
+
+func TestVerifyReportsDrift(t *testing.T) {
+	probe := func(name string) Check {
+		return Check{Binary: name, Version: &VersionProbe{Command: Command{Name: name}, Pattern: `(.*)`}}
+	}
+	catalog := map[string]ToolSpec{
+		"go":     {ID: "go", Check: probe("go"), Source: "homebrew/homebrew-core"},
+		"nodejs": {ID: "nodejs", Check: probe("node"), Source: "homebrew/homebrew-core"},
+		"docker": {ID: "docker", Check: probe("docker"), Source: "homebrew/cask"},
+		"git":    {ID: "git", Check: probe("git"), Source: "homebrew/homebrew-core"},
+	}
+	lock := Lockfile{Tools: []LockedTool{
+		{ID: "docker", Version: "25.0.3", Source: "homebrew/cask"},
+		{ID: "git", Version: "2.44.0", Source: "homebrew/homebrew-core"},
+		{ID: "go", Version: "1.23.4", Source: "homebrew/homebrew-core"},
+		{ID: "nodejs", Version: "20.11.1", Source: "nodesource"},
+		{ID: "rust", Version: "1.76.0"},
+	}}
+	executor := newTestExecutor(map[string]string{"go": "v1.23.4", "node": "20.11.1", "git": "2.43.0"})
+
+	report := executor.Verify(lock, catalog)
+	want := []Drift{
+		{ToolID: "docker", Kind: DriftMissing, Expected: "25.0.3"},
+		{ToolID: "git", Kind: DriftVersionMismatch, Expected: "2.44.0", Actual: "2.43.0"},
+		{ToolID: "nodejs", Kind: DriftSourceMismatch, Expected: "nodesource", Actual: "homebrew/homebrew-core"},
+		{ToolID: "rust", Kind: DriftUnknownTool},
+	}
+	if report.Checked != 5 || len(report.Drift) != len(want) {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+	for i := range want {
+		if report.Drift[i] != want[i] {
+			t.Fatalf("drift %d: got %#v, want %#v", i, report.Drift[i], want[i])
+		}
+	}
+}
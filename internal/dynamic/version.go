@@ -0,0 +1,189 @@
+package dynamic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. Missing minor or patch components are
+// zero and pre-release or build suffixes are ignored.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func ParseVersion(s string) (Version, error) {
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if idx := strings.IndexAny(raw, "-+"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	parts := strings.Split(raw, ".")
+	if raw == "" || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	nums := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Major != o.Major:
+		return compareInt(v.Major, o.Major)
+	case v.Minor != o.Minor:
+		return compareInt(v.Minor, o.Minor)
+	default:
+		return compareInt(v.Patch, o.Patch)
+	}
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Constraint is a set of alternatives ("||"), each a list of comparisons
+// that must all hold, e.g. ">=1.22 <1.24 || ^2.0".
+type Constraint struct {
+	raw          string
+	alternatives [][]versionComparison
+}
+
+type versionComparison struct {
+	op      string
+	version Version
+}
+
+// ParseConstraint accepts comparisons separated by spaces or commas using
+// the operators =, ==, !=, >, >=, <, <=, ~ (patch updates) and ^ (minor and
+// patch updates). A bare version means "=".
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	for _, alternative := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(alternative, func(r rune) bool { return r == ' ' || r == ',' })
+		if len(fields) == 0 {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q", s)
+		}
+		comparisons := []versionComparison{}
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := field[:len(field)-len(strings.TrimLeft(field, "=!<>~^"))]
+			rest := strings.TrimPrefix(field, op)
+			if rest == "" && i+1 < len(fields) {
+				// Allow a space between the operator and the version.
+				i++
+				rest = fields[i]
+			}
+			switch op {
+			case "", "=", "==", "!=", ">", ">=", "<", "<=", "~", "^":
+			default:
+				return Constraint{}, fmt.Errorf("invalid operator %q in version constraint %q", op, s)
+			}
+			v, err := ParseVersion(rest)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			comparisons = append(comparisons, expandComparison(op, v, rest)...)
+		}
+		c.alternatives = append(c.alternatives, comparisons)
+	}
+	return c, nil
+}
+
+// expandComparison rewrites ~ and ^ into a >=/< range. A bare or "=" version
+// with fewer than three components matches the whole range it names, so
+// "1.22" accepts any 1.22.x.
+func expandComparison(op string, v Version, raw string) []versionComparison {
+	components := strings.Count(strings.TrimPrefix(raw, "v"), ".") + 1
+	switch op {
+	case "~":
+		return []versionComparison{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}
+	case "^":
+		if v.Major == 0 {
+			return []versionComparison{{">=", v}, {"<", Version{Minor: v.Minor + 1}}}
+		}
+		return []versionComparison{{">=", v}, {"<", Version{Major: v.Major + 1}}}
+	case "", "=", "==":
+		switch components {
+		case 1:
+			return []versionComparison{{">=", v}, {"<", Version{Major: v.Major + 1}}}
+		case 2:
+			return []versionComparison{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}
+		}
+		return []versionComparison{{"=", v}}
+	}
+	return []versionComparison{{op, v}}
+}
+
+func (c Constraint) Check(v Version) bool {
+	for _, alternative := range c.alternatives {
+		ok := true
+		for _, cmp := range alternative {
+			if !cmp.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
+
+func (cmp versionComparison) matches(v Version) bool {
+	n := v.Compare(cmp.version)
+	switch cmp.op {
+	case "=":
+		return n == 0
+	case "!=":
+		return n != 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	}
+	return false
+}
+
+// SatisfiesConstraint reports whether version satisfies constraint. An empty
+// constraint is always satisfied.
+func SatisfiesConstraint(version string, constraint string) (bool, error) {
+	if strings.TrimSpace(constraint) == "" {
+		return true, nil
+	}
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
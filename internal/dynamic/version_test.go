@@ -0,0 +1,45 @@
+package dynamic
+
+import "testing"
+
+func TestSatisfiesConstraint(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"1.23.4", ">=1.22 <1.24", true},
+		{"1.24.0", ">=1.22 <1.24", false},
+		{"1.22", ">=1.22, <1.24", true},
+		{"v20.11.1", "^20", true},
+		{"21.0.0", "^20", false},
+		{"0.3.9", "^0.3.1", true},
+		{"0.4.0", "^0.3.1", false},
+		{"3.12.2", "~3.12", true},
+		{"3.13.0", "~3.12", false},
+		{"2.44.0", "2.44", true},
+		{"2.45.0", "2.44", false},
+		{"2.44.1", "= 2.44.0", false},
+		{"1.0.0", "<1.0.0 || >=2.0.0", false},
+		{"2.1.0", "<1.0.0 || >=2.0.0", true},
+		{"8.0.100-rc.1", ">=8", true},
+		{"anything", "", true},
+	}
+	for _, tt := range tests {
+		got, err := SatisfiesConstraint(tt.version, tt.constraint)
+		if err != nil {
+			t.Fatalf("SatisfiesConstraint(%q, %q) error: %v", tt.version, tt.constraint, err)
+		}
+		if got != tt.want {
+			t.Errorf("SatisfiesConstraint(%q, %q) = %v, want %v", tt.version, tt.constraint, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraintRejectsInvalidInput(t *testing.T) {
+	for _, constraint := range []string{">>1.0", "latest", ">=1.x", "||"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("ParseConstraint(%q): expected error", constraint)
+		}
+	}
+}
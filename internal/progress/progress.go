@@ -0,0 +1,340 @@
+// Package progress renders the steps of an install as a live list on a
+// terminal, or as one line per status change when output is not a terminal.
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+const (
+	defaultTailLines = 5
+	defaultWidth     = 80
+	plainLineWidth   = 120
+	redrawInterval   = 100 * time.Millisecond
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+type Options struct {
+	// Plain prints one line per status change instead of redrawing the
+	// step list in place.
+	Plain bool
+	// TailLines is how many output lines a running or failed step shows.
+	TailLines int
+	// Width is the terminal width live lines are cut to; zero means 80.
+	Width int
+}
+
+// DetectOptions renders live only when f is a terminal and neither plain nor
+// NO_COLOR asks for plain output.
+func DetectOptions(f *os.File, plain bool) Options {
+	opts := Options{
+		Plain:     plain || os.Getenv("NO_COLOR") != "" || !isTerminal(f),
+		TailLines: defaultTailLines,
+	}
+	if !opts.Plain {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			opts.Width = width
+		}
+	}
+	return opts
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+type step struct {
+	id      string
+	title   string
+	status  Status
+	detail  string
+	started time.Time
+	ended   time.Time
+	tail    []string
+}
+
+// Renderer is safe for concurrent use. Close must be called once the steps
+// are finished.
+type Renderer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	opts  Options
+	steps []*step
+	index map[string]*step
+	drawn int
+	frame int
+	now   func() time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(w io.Writer, opts Options) *Renderer {
+	if opts.TailLines <= 0 {
+		opts.TailLines = defaultTailLines
+	}
+	switch {
+	case opts.Plain:
+		// Plain lines never redraw, so wrapping only costs readability.
+		opts.Width = plainLineWidth
+	case opts.Width <= 0:
+		opts.Width = defaultWidth
+	}
+	r := &Renderer{w: w, opts: opts, index: map[string]*step{}, now: time.Now}
+	if !opts.Plain {
+		r.stop = make(chan struct{})
+		r.done = make(chan struct{})
+		go r.tick()
+	}
+	return r
+}
+
+// Add lists a pending step. Steps started without being added are listed
+// when they start, titled by their id.
+func (r *Renderer) Add(id, title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(id, title)
+}
+
+func (r *Renderer) add(id, title string) *step {
+	if s, ok := r.index[id]; ok {
+		return s
+	}
+	s := &step{id: id, title: title, status: StatusPending}
+	r.steps = append(r.steps, s)
+	r.index[id] = s
+	return s
+}
+
+func (r *Renderer) Start(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.add(id, id)
+	s.status = StatusRunning
+	s.started = r.now()
+	s.detail = ""
+	s.tail = nil
+	if r.opts.Plain {
+		fmt.Fprintf(r.w, "→ %s\n", s.title)
+		return
+	}
+	r.redraw()
+}
+
+// Output records a line of the step's output for its tail.
+func (r *Renderer) Output(id, line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.add(id, id)
+	s.tail = append(s.tail, line)
+	if len(s.tail) > r.opts.TailLines {
+		s.tail = s.tail[len(s.tail)-r.opts.TailLines:]
+	}
+}
+
+// Writer returns a writer that passes each line written to it to Output.
+// Closing it flushes a trailing partial line.
+func (r *Renderer) Writer(id string) io.WriteCloser {
+	return NewLineWriter(func(line string) { r.Output(id, line) })
+}
+
+// Finish sets the step's final status. detail is shown next to the title,
+// for example a version, a skip reason or an error.
+func (r *Renderer) Finish(id string, status Status, detail string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.add(id, id)
+	s.status = status
+	s.detail = detail
+	s.ended = r.now()
+	if r.opts.Plain {
+		fmt.Fprintln(r.w, r.stepLine(s))
+		if status == StatusFailed {
+			for _, line := range s.tail {
+				fmt.Fprintln(r.w, r.tailLine(line))
+			}
+		}
+		return
+	}
+	r.redraw()
+}
+
+// Close stops redrawing and leaves the final list on screen.
+func (r *Renderer) Close() {
+	if r.opts.Plain {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redraw()
+}
+
+func (r *Renderer) tick() {
+	defer close(r.done)
+	ticker := time.NewTicker(redrawInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.frame++
+			r.redraw()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// redraw moves the cursor back over the previous frame and rewrites it.
+// Running and failed steps show their output tail; finished ones collapse.
+func (r *Renderer) redraw() {
+	lines := []string{}
+	for _, s := range r.steps {
+		lines = append(lines, r.stepLine(s))
+		if s.status == StatusRunning || s.status == StatusFailed {
+			for _, line := range s.tail {
+				lines = append(lines, r.tailLine(line))
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if r.drawn > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dA", r.drawn)
+	}
+	for _, line := range lines {
+		buf.WriteString("\r\x1b[2K")
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\x1b[J")
+	r.drawn = len(lines)
+	_, _ = r.w.Write(buf.Bytes())
+}
+
+func (r *Renderer) stepLine(s *step) string {
+	var icon string
+	switch s.status {
+	case StatusPending:
+		icon = "·"
+	case StatusRunning:
+		icon = spinnerFrames[r.frame%len(spinnerFrames)]
+	case StatusDone:
+		icon = "✓"
+	case StatusSkipped:
+		icon = "-"
+	case StatusFailed:
+		icon = "✗"
+	}
+	if !r.opts.Plain {
+		icon = colorize(s.status, icon)
+	}
+	line := icon + " " + s.title
+	switch {
+	case s.status == StatusRunning:
+		line += " (" + formatElapsed(r.now().Sub(s.started)) + ")"
+	case !s.started.IsZero() && !s.ended.IsZero():
+		line += " (" + formatElapsed(s.ended.Sub(s.started)) + ")"
+	}
+	if s.detail != "" {
+		line += ": " + s.detail
+	}
+	return truncate(line, r.opts.Width)
+}
+
+func colorize(status Status, s string) string {
+	code := ""
+	switch status {
+	case StatusDone:
+		code = "32"
+	case StatusFailed:
+		code = "31"
+	case StatusRunning:
+		code = "36"
+	default:
+		code = "2"
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// tailLine formats an output line for display. Progress meters such as
+// brew's and curl's rewrite their line with "\r", so only the text after
+// the last one is what a terminal would show.
+func (r *Renderer) tailLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if idx := strings.LastIndexByte(line, '\r'); idx >= 0 {
+		line = line[idx+1:]
+	}
+	return truncate("    │ "+line, r.opts.Width)
+}
+
+// truncate keeps lines from wrapping, which would throw off redraws. The
+// last column is left free, as some terminals wrap when it is written.
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) < width {
+		return line
+	}
+	return string(runes[:width-2]) + "…"
+}
+
+func formatElapsed(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// LineWriter calls onLine for every complete line written to it; Close
+// passes on a trailing partial line.
+type LineWriter struct {
+	onLine func(line string)
+	part   []byte
+}
+
+func NewLineWriter(onLine func(line string)) *LineWriter {
+	return &LineWriter{onLine: onLine}
+}
+
+func (l *LineWriter) Write(b []byte) (int, error) {
+	l.part = append(l.part, b...)
+	for {
+		idx := bytes.IndexByte(l.part, '\n')
+		if idx < 0 {
+			break
+		}
+		l.onLine(string(l.part[:idx]))
+		l.part = l.part[idx+1:]
+	}
+	return len(b), nil
+}
+
+func (l *LineWriter) Close() error {
+	if len(l.part) > 0 {
+		l.onLine(string(l.part))
+		l.part = nil
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func fixedClock(r *Renderer) *time.Time {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return &now
+}
+
+func TestPlainRendererPrintsStatusChanges(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf, Options{Plain: true, TailLines: 2})
+	now := fixedClock(r)
+	r.Add("git", "Git")
+	r.Add("go", "Go")
+	r.Start("git")
+	*now = now.Add(1500 * time.Millisecond)
+	r.Finish("git", StatusDone, "2.44.0")
+	r.Start("go")
+	w := r.Writer("go")
+	w.Write([]byte("one\ntwo\nthree"))
+	w.Close()
+	r.Finish("go", StatusFailed, "exit status 1")
+	r.Close()
+
+	want := strings.Join([]string{
+		"→ Git",
+		"✓ Git (1.5s): 2.44.0",
+		"→ Go",
+		"✗ Go (0s): exit status 1",
+		"    │ two",
+		"    │ three",
+		"",
+	}, "\n")
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestLiveRendererCollapsesFinishedSteps(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf, Options{TailLines: 3})
+	fixedClock(r)
+	r.Start("git")
+	r.Output("git", "downloading")
+	r.Finish("git", StatusDone, "")
+	r.Close()
+
+	out := buf.String()
+	frames := strings.Split(out, "\x1b[J")
+	final := frames[len(frames)-2]
+	if !strings.Contains(final, "\x1b[1A") || !strings.Contains(final, "git") {
+		t.Fatalf("expected the final frame to redraw over one line, got %q", final)
+	}
+	if strings.Contains(final, "downloading") {
+		t.Fatalf("expected finished step to collapse its output, got %q", final)
+	}
+}
+
+func TestLiveRendererFitsTerminalWidthAndProgressMeters(t *testing.T) {
+	var buf bytes.Buffer
+	r := New(&buf, Options{Width: 40})
+	fixedClock(r)
+	r.Start("docker")
+	r.Output("docker", "######                 10.0%\r#############          50.0%\r")
+	r.Output("docker", strings.Repeat("x", 100))
+	r.Finish("docker", StatusFailed, "exit status 1")
+	r.Close()
+
+	frames := strings.Split(buf.String(), "\x1b[J")
+	final := frames[len(frames)-2]
+	if strings.Contains(final, "10.0%") || !strings.Contains(final, "50.0%") {
+		t.Fatalf("expected only the last progress update, got %q", final)
+	}
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(final, ""), "\n") {
+		if n := len([]rune(strings.TrimLeft(line, "\r"))); n >= 40 {
+			t.Fatalf("expected lines to fit 40 columns, got %d in %q", n, line)
+		}
+	}
+	if !strings.Contains(final, "…") {
+		t.Fatalf("expected the long output line to be cut, got %q", final)
+	}
+}
+
+func TestDetectOptionsHonoursNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if opts := DetectOptions(nil, false); !opts.Plain {
+		t.Fatal("expected NO_COLOR to select plain output")
+	}
+}
+
+func TestDetectOptionsTreatsDevNullAsPlain(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skipf("open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	if opts := DetectOptions(devNull, false); !opts.Plain {
+		t.Fatalf("expected %s not to be treated as a terminal", os.DevNull)
+	}
+}
+
+func TestLineWriterSplitsLinesAndFlushesOnClose(t *testing.T) {
+	var lines []string
+	w := NewLineWriter(func(line string) { lines = append(lines, line) })
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	if strings.Join(lines, ",") != "one,two" {
+		t.Fatalf("expected complete lines only, got %q", lines)
+	}
+	_ = w.Close()
+	if strings.Join(lines, ",") != "one,two,three" {
+		t.Fatalf("expected Close to flush the partial line, got %q", lines)
+	}
+}